@@ -13,6 +13,7 @@ import (
 	"html/template"
 	"io/ioutil"
 	"log"
+	"sync"
 
 	"github.com/hyper-prog/smartyaml"
 )
@@ -20,8 +21,15 @@ import (
 type PanelAction struct {
 	PanelBase
 
-	Commands      string
-	RelatedPanels []string
+	Commands        string
+	source          string
+	OnError         string
+	Trace           bool
+	RunInBackground bool
+	lastError       string
+	lastRelated     []string
+	running         bool
+	mutex           sync.Mutex
 }
 
 func NewPanelAction() *PanelAction {
@@ -38,7 +46,7 @@ func NewPanelAction() *PanelAction {
 			hasPowerInfo: false,
 			index:        0,
 		},
-		"", []string{},
+		"", "", "", false, false, "", []string{}, false, sync.Mutex{},
 	}
 }
 
@@ -53,9 +61,21 @@ func (p *PanelAction) LoadCustomConfig(sy smartyaml.SmartYAML, indexInConfig int
 			p.Commands = string(commandFileProgram)
 		}
 	}
+	p.source = p.Commands
+	if expanded, err := expandIncludes(p.Commands); err == nil {
+		p.Commands = expanded
+	} else {
+		log.Printf("Error in the commands of panel %s: %s\n", p.idStr, err.Error())
+	}
+	p.OnError = sy.GetStringByPathWithDefault(fmt.Sprintf("/GlowDash/Panels/[%d]/OnError", indexInConfig), "")
+	p.Trace = sy.GetStringByPathWithDefault(fmt.Sprintf("/GlowDash/Panels/[%d]/Trace", indexInConfig), "no") == "yes"
+	p.RunInBackground = false
+	if sy.GetStringByPathWithDefault(fmt.Sprintf("/GlowDash/Panels/[%d]/RunInBackground", indexInConfig), "no") == "yes" {
+		p.RunInBackground = true
+	}
 }
 
-func (p PanelAction) PanelHtml(withContainer bool) string {
+func (p *PanelAction) PanelHtml(withContainer bool) string {
 	templ, _ := template.New("PcT").Parse(`
 	<div class="badge badge-left" style="max-width: 100%;">
 		<div class="label label-s no-radius-bottom-left-diagonal">
@@ -76,13 +96,23 @@ func (p PanelAction) PanelHtml(withContainer bool) string {
 			<div class="title-container mt-s">
 				<p class="title text-bold body-small-styles">{{.Title}}</p>
 			</div>
+			{{if .Running}}
+			<div class="title-container mt-s">
+				<p class="text-600 body-small-styles">{{.RunningLabel}}</p>
+			</div>
+			{{end}}
+			{{if .HasError}}
+			<div class="title-container mt-s">
+				<p class="text-600 body-small-styles actionerror" title="{{.ErrorText}}">{{.ErrorLabel}}</p>
+			</div>
+			{{end}}
 		</div>
 
 		<div class="bottom-slot-container d-flex justify-content-center">
 			<button id="b-{{.Id}}-run" class="align-self-center device-button primary medium jsaction {{if eq .State 0}}inactive{{end}}">
 				<span class="device-action-border">
 					<span class="device-action">
-						<span class="text-primary icon-grid icon-grid-s">
+						<span class="text-primary icon-grid icon-grid-s {{if .Running}}animated-border-box{{end}}">
 							<i class="fa fa-action"></i>
 						</span>
 					</span>
@@ -91,18 +121,33 @@ func (p PanelAction) PanelHtml(withContainer bool) string {
 		</div>
 	</div>`)
 
+	p.mutex.Lock()
+	running := p.running
+	lastError := p.lastError
+	p.mutex.Unlock()
+
 	pass := struct {
-		Title    string
-		Id       string
-		PTypText string
-		ThumbImg string
-		State    int
+		Title        string
+		Id           string
+		PTypText     string
+		ThumbImg     string
+		State        int
+		HasError     bool
+		ErrorLabel   string
+		ErrorText    string
+		Running      bool
+		RunningLabel string
 	}{
-		Title:    p.title,
-		Id:       p.idStr,
-		PTypText: T("Action"),
-		ThumbImg: p.thumbImg,
-		State:    0,
+		Title:        p.title,
+		Id:           p.idStr,
+		PTypText:     T("Action"),
+		ThumbImg:     p.thumbImg,
+		State:        0,
+		HasError:     lastError != "" && !running,
+		ErrorLabel:   T("Failed"),
+		ErrorText:    lastError,
+		Running:      running,
+		RunningLabel: T("Running..."),
 	}
 
 	buffer := bytes.Buffer{}
@@ -116,7 +161,7 @@ func (p PanelAction) PanelHtml(withContainer bool) string {
 	return buffer.String()
 }
 
-func (p PanelAction) IsActionIdMatch(aId string) bool {
+func (p *PanelAction) IsActionIdMatch(aId string) bool {
 	if "b-"+p.idStr+"-run" == aId {
 		return true
 	}
@@ -126,22 +171,105 @@ func (p PanelAction) IsActionIdMatch(aId string) bool {
 	return false
 }
 
-func (p PanelAction) DoAction(actionName string, parameters map[string]string) (string, []string, bool) {
+// The commands with the included files, they can be changed by the library reload
+func (p *PanelAction) commands() string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.Commands
+}
+
+// Expands the includes of the commands again (library reload)
+func (p *PanelAction) reloadIncludes() error {
+	expanded, err := expandIncludes(p.source)
+	if err != nil {
+		return err
+	}
+	p.mutex.Lock()
+	p.Commands = expanded
+	p.mutex.Unlock()
+	return nil
+}
+
+// Runs the commands and returns the panels set by RelatedPanel commands.
+// The related panels are collected per run, because the same action can run parallel.
+func (p *PanelAction) runCommands(initVariables map[string]string) []string {
+	relatedPanels := []string{}
+	results := ExecuteCommandsWithOptions(p.commands(), initVariables, &relatedPanels,
+		ProgramRunOptions{Name: p.title, OnError: p.OnError, Trace: p.Trace})
+	p.mutex.Lock()
+	p.lastError = results["Error"]
+	p.lastRelated = relatedPanels
+	p.mutex.Unlock()
+	return relatedPanels
+}
+
+// Runs the commands as a nested program of the calling program (Panel <id> run)
+func (p *PanelAction) runCommandsNested(ctx *RunContext) []string {
+	initVariables := map[string]string{}
+	initVariables["ActionPanel.RunType"] = "Program"
+	initVariables["ActionPanel.Title"] = p.title
+	initVariables["ActionPanel.Id"] = p.idStr
+	initVariables["ActionPanel.DeviceType"] = p.deviceType
+	options := nestedProgramRunOptions(ctx, p.title)
+	options.OnError = p.OnError
+	options.Trace = p.Trace
+	relatedPanels := []string{}
+	results := ExecuteCommandsWithOptions(p.commands(), initVariables, &relatedPanels, options)
+	if ctx.options.dryRun == nil {
+		p.mutex.Lock()
+		p.lastError = results["Error"]
+		p.lastRelated = relatedPanels
+		p.mutex.Unlock()
+	}
+	if results["Aborted"] == "true" {
+		RaiseError(ctx, results["Error"])
+	}
+	return append(getUpdatedIdsFromRelatedPanels(relatedPanels), p.idStr)
+}
+
+// Starts the commands in background, returns false if the previous run is not finished yet
+func (p *PanelAction) startCommands(initVariables map[string]string) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.running {
+		return false
+	}
+	p.running = true
+	startBackgroundProgram(p.Commands, initVariables, ProgramRunOptions{Name: p.title, OnError: p.OnError, Trace: p.Trace},
+		func(results map[string]string, updatedIds []string) []string {
+			p.mutex.Lock()
+			p.running = false
+			p.lastError = results["Error"]
+			p.mutex.Unlock()
+			return append(updatedIds, p.idStr)
+		})
+	return true
+}
+
+func (p *PanelAction) DoAction(actionName string, parameters map[string]string) (string, []string, bool) {
 	var stateChanged bool = false
 	var updatedIds []string = []string{}
 	if actionName == "run" {
-		p.RelatedPanels = []string{}
 		initVariables := map[string]string{}
 		initVariables["ActionPanel.RunType"] = "UserAction"
 		initVariables["ActionPanel.Title"] = p.title
 		initVariables["ActionPanel.Id"] = p.idStr
 		initVariables["ActionPanel.DeviceType"] = p.deviceType
+		if p.RunInBackground {
+			if p.startCommands(initVariables) {
+				GlowdashConsole.Write(T("Run action \"{{title}}\" in background", map[string]any{"title": p.eventtitle}))
+				stateChanged = true
+			}
+			updatedIds = append(updatedIds, p.idStr)
+			return "ok", updatedIds, stateChanged
+		}
 		GlowdashConsole.Write(T("Run action \"{{title}}\"", map[string]any{"title": p.eventtitle}))
-		ExecuteCommands(p.Commands, initVariables, &(p.RelatedPanels))
-		if len(p.RelatedPanels) > 0 {
+		relatedPanels := p.runCommands(initVariables)
+		if len(relatedPanels) > 0 {
 			stateChanged = true
 		}
-		updatedIds = append(updatedIds, p.QueryDevice()...)
+		updatedIds = append(updatedIds, getUpdatedIdsFromRelatedPanels(relatedPanels)...)
+		updatedIds = append(updatedIds, p.idStr)
 	}
 	if actionName == "update" {
 		updatedIds = append(updatedIds, p.QueryDevice()...)
@@ -149,23 +277,44 @@ func (p PanelAction) DoAction(actionName string, parameters map[string]string) (
 	return "ok", updatedIds, stateChanged
 }
 
-func (p PanelAction) DoActionFromScheduler(actionName string) []string {
+func (p *PanelAction) DoActionFromScheduler(actionName string) ([]string, error) {
 	if actionName == "run" {
-		p.RelatedPanels = []string{}
 		initVariables := map[string]string{}
 		initVariables["ActionPanel.RunType"] = "ScheduledTask"
 		initVariables["ActionPanel.Title"] = p.title
 		initVariables["ActionPanel.Id"] = p.idStr
 		initVariables["ActionPanel.DeviceType"] = p.deviceType
+		if p.RunInBackground {
+			if !p.startCommands(initVariables) {
+				return []string{p.idStr}, fmt.Errorf("the program is already running")
+			}
+			GlowdashConsole.Write(T("Scheduled run action \"{{title}}\" in background", map[string]any{"title": p.eventtitle}))
+			return []string{p.idStr}, nil
+		}
 		GlowdashConsole.Write(T("Scheduled run action \"{{title}}\"", map[string]any{"title": p.eventtitle}))
-		ExecuteCommands(p.Commands, initVariables, &(p.RelatedPanels))
-		return p.QueryDevice()
+		relatedPanels := p.runCommands(initVariables)
+		updatedIds := append(getUpdatedIdsFromRelatedPanels(relatedPanels), p.idStr)
+		p.mutex.Lock()
+		lastError := p.lastError
+		p.mutex.Unlock()
+		if lastError != "" {
+			return updatedIds, fmt.Errorf("%s", lastError)
+		}
+		return updatedIds, nil
+	}
+	return []string{}, errUnknownScheduledAction
+}
+
+func (p *PanelAction) SchedulableActions() []SchedulableAction {
+	return []SchedulableAction{
+		{Name: "run", Title: "Run"},
 	}
-	return []string{}
 }
 
 func (p *PanelAction) QueryDevice() []string {
-	return append(getUpdatedIdsFromRelatedPanels(p.RelatedPanels), p.idStr)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append(getUpdatedIdsFromRelatedPanels(p.lastRelated), p.idStr)
 }
 
 func (p *PanelAction) SetHwDeviceId(id int) {
@@ -176,7 +325,7 @@ func (p *PanelAction) RefreshHwStateIfMatch(fromPanelType PanelTypes, fromDevice
 	return p.idStr
 }
 
-func (p PanelAction) ExposeVariables() map[string]string {
+func (p *PanelAction) ExposeVariables() map[string]string {
 
 	var m map[string]string = map[string]string{}
 
@@ -0,0 +1,471 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The JSON api (/api/v1) for the other clients (phone widgets, scripts, Stream Deck...).
+// The api is described in the openapi.yaml, which is served on /api/v1/openapi.yaml
+//
+//go:embed openapi.yaml
+var apiOpenApiDescription string
+
+// If it is set, the api requests must have the "Authorization: Bearer <token>" header
+var ApiToken string = ""
+
+// The maximum size of the request body
+const apiMaxBodySize int64 = 65536
+
+var apiPanelTypeNames = map[PanelTypes]string{
+	Group:              "Group",
+	Switch:             "Switch",
+	Shading:            "Shading",
+	Action:             "Action",
+	Script:             "Script",
+	Thermostat:         "Thermostat",
+	ThermostatSwitch:   "ThermostatSwitch",
+	Sensors:            "Sensors",
+	Launch:             "Launch",
+	ScheduleShortcut:   "ScheduleShortcut",
+	ScheduleProfile:    "ScheduleProfile",
+	PresenceSimulation: "PresenceSimulation",
+}
+
+var apiPageTypeNames = map[PageTypes]string{
+	ScheduleEdit:    "ScheduleEdit",
+	Console:         "Console",
+	SensorStats:     "SensorStats",
+	SensorGraph:     "SensorGraph",
+	RunningScripts:  "RunningScripts",
+	ScriptTest:      "ScriptTest",
+	ScriptTraces:    "ScriptTraces",
+	ScheduleHistory: "ScheduleHistory",
+}
+
+var apiParamTypeNames = map[ScheduleParamTypes]string{
+	ParamNone:   "none",
+	ParamBool:   "bool",
+	ParamNumber: "number",
+	ParamEnum:   "enum",
+}
+
+type apiPanel struct {
+	Id      string            `json:"id"`
+	Title   string            `json:"title"`
+	Type    string            `json:"type"`
+	SubPage string            `json:"subpage"`
+	Hidden  bool              `json:"hidden"`
+	Actions []apiAction       `json:"actions"`
+	State   map[string]string `json:"state"`
+}
+
+// A schedulable action of the panel, the other (button) actions of the panels can be called too
+type apiAction struct {
+	Name   string   `json:"name"`
+	Param  string   `json:"param"`
+	Min    float64  `json:"min,omitempty"`
+	Max    float64  `json:"max,omitempty"`
+	Step   float64  `json:"step,omitempty"`
+	Values []string `json:"values,omitempty"`
+}
+
+type apiPage struct {
+	Id    string `json:"id"`
+	Title string `json:"title"`
+	Type  string `json:"type"`
+	Url   string `json:"url"`
+}
+
+type apiActionRequest struct {
+	Param *string `json:"param"`
+}
+
+type apiActionResult struct {
+	Result  string   `json:"result"`
+	Updated []string `json:"updated"`
+}
+
+type apiStateVariable struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func apiWriteJson(w http.ResponseWriter, status int, v any) {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, "500 Internal Server Error", 500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(content)
+}
+
+func apiWriteError(w http.ResponseWriter, status int, message string) {
+	apiWriteJson(w, status, apiError{Error: message})
+}
+
+// Reads the JSON body of the request, returns false (and writes the error) if it is wrong
+func apiReadBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	content, err := io.ReadAll(io.LimitReader(r.Body, apiMaxBodySize))
+	if err != nil {
+		apiWriteError(w, 400, "Cannot read the request body")
+		return false
+	}
+	if len(strings.TrimSpace(string(content))) == 0 {
+		return true
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		apiWriteError(w, 400, "Wrong JSON: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func apiAuthorized(r *http.Request) bool {
+	if ApiToken == "" {
+		return true
+	}
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return found && subtle.ConstantTimeCompare([]byte(token), []byte(ApiToken)) == 1
+}
+
+func apiPanelData(p PanelInterface) apiPanel {
+	actions := []apiAction{}
+	for _, a := range p.SchedulableActions() {
+		actions = append(actions, apiAction{Name: a.Name, Param: apiParamTypeNames[a.Param], Min: a.Min, Max: a.Max, Step: a.Step, Values: a.Values})
+	}
+	return apiPanel{Id: p.IdStr(), Title: p.Title(), Type: apiPanelTypeNames[p.PanelType()], SubPage: p.Sub(),
+		Hidden: p.IsHide(), Actions: actions, State: p.ExposeVariables()}
+}
+
+// The /api/v1/... requests
+func handleApi(w http.ResponseWriter, r *http.Request) {
+	if DebugLevel > 0 {
+		fmt.Printf("API: %s %s\n", r.Method, r.URL.Path)
+	}
+	// The segments are unescaped one by one, so the schedule names can contain slash
+	path := strings.Trim(strings.TrimPrefix(r.URL.EscapedPath(), "/api/v1"), "/")
+	if path == "openapi.yaml" {
+		w.Header().Set("Content-Type", "application/yaml")
+		io.WriteString(w, apiOpenApiDescription)
+		return
+	}
+	if !apiAuthorized(r) {
+		apiWriteError(w, 401, "Unauthorized")
+		return
+	}
+
+	parts := strings.Split(path, "/")
+	for i := range parts {
+		part, err := url.PathUnescape(parts[i])
+		if err != nil {
+			apiWriteError(w, 400, "Wrong url")
+			return
+		}
+		parts[i] = part
+	}
+	switch parts[0] {
+	case "panels":
+		apiPanels(w, r, parts[1:])
+	case "pages":
+		apiPages(w, r, parts[1:])
+	case "schedules":
+		apiSchedules(w, r, parts[1:])
+	case "state":
+		apiState(w, r, parts[1:])
+	default:
+		apiWriteError(w, 404, "Not found")
+	}
+}
+
+// GET panels, GET panels/<id>, POST panels/<id>/actions/<action>
+func apiPanels(w http.ResponseWriter, r *http.Request, parts []string) {
+	if len(parts) == 0 {
+		if r.Method != "GET" {
+			apiWriteError(w, 405, "Method not allowed")
+			return
+		}
+		list := []apiPanel{}
+		for i := 0; i < len(Panels); i++ {
+			list = append(list, apiPanelData(Panels[i]))
+		}
+		apiWriteJson(w, 200, list)
+		return
+	}
+
+	panel := GetPanelById(parts[0])
+	if panel == nil {
+		apiWriteError(w, 404, "Unknown panel id: "+parts[0])
+		return
+	}
+	if len(parts) == 1 {
+		if r.Method != "GET" {
+			apiWriteError(w, 405, "Method not allowed")
+			return
+		}
+		apiWriteJson(w, 200, apiPanelData(panel))
+		return
+	}
+	if len(parts) != 3 || parts[1] != "actions" || parts[2] == "" {
+		apiWriteError(w, 404, "Not found")
+		return
+	}
+	if r.Method != "POST" {
+		apiWriteError(w, 405, "Method not allowed")
+		return
+	}
+
+	req := apiActionRequest{}
+	if !apiReadBody(w, r, &req) {
+		return
+	}
+	param := ""
+	if req.Param != nil {
+		param = *req.Param
+	}
+	result, updatedIds, err := RunPanelAction(panel, parts[2], param, req.Param != nil)
+	// The panels are refreshed on the dashboards after the failed device operation too
+	if len(updatedIds) > 0 {
+		panelUpdateRequestSSE(updatedIds)
+	}
+	if errors.Is(err, errDeviceOperationFailed) {
+		apiWriteError(w, 502, err.Error())
+		return
+	}
+	if err != nil {
+		apiWriteError(w, 400, err.Error())
+		return
+	}
+	if updatedIds == nil {
+		updatedIds = []string{}
+	}
+	apiWriteJson(w, 200, apiActionResult{Result: result, Updated: updatedIds})
+}
+
+// GET pages
+func apiPages(w http.ResponseWriter, r *http.Request, parts []string) {
+	if len(parts) != 0 || r.Method != "GET" {
+		apiWriteError(w, 405, "Method not allowed")
+		return
+	}
+	list := []apiPage{}
+	for i := 0; i < len(Pages); i++ {
+		list = append(list, apiPage{Id: Pages[i].IdStr(), Title: Pages[i].Title(), Type: apiPageTypeNames[Pages[i].PageType()],
+			Url: "/page/" + Pages[i].IdStr()})
+	}
+	apiWriteJson(w, 200, list)
+}
+
+// Sets the action type of the schedule from the panel and checks the action and the end action
+// against the schedulable actions of the panel
+func apiScheduleAction(s *Schedule) error {
+	panel := GetPanelById(s.actionId)
+	if panel == nil {
+		return fmt.Errorf("unknown panel: %s", s.actionId)
+	}
+	actionType, ok := scheduleActionTypeNames[panel.PanelType()]
+	actions := panel.SchedulableActions()
+	if !ok || len(actions) == 0 {
+		return fmt.Errorf("the panel has no schedulable action: %s", s.actionId)
+	}
+	s.actionType = actionType
+	if err := checkSchedulableAction(actions, s.actionParam); err != nil {
+		return err
+	}
+	if s.endAction != "" {
+		if err := checkSchedulableAction(actions, s.endAction); err != nil {
+			return fmt.Errorf("end action: %s", err)
+		}
+	}
+	return nil
+}
+
+// GET, POST schedules, GET, PUT, DELETE schedules/<name>
+// The schedules have the format of the schedules file, the one shot schedules are not listed.
+func apiSchedules(w http.ResponseWriter, r *http.Request, parts []string) {
+	if len(parts) > 1 {
+		apiWriteError(w, 404, "Not found")
+		return
+	}
+	if len(parts) == 0 {
+		if r.Method == "GET" {
+			list := []scheduleRecord{}
+			scheduleMutex.Lock()
+			for _, s := range schedules {
+				if !s.oneshot {
+					list = append(list, scheduleToRecord(s))
+				}
+			}
+			scheduleMutex.Unlock()
+			apiWriteJson(w, 200, list)
+			return
+		}
+		if r.Method != "POST" {
+			apiWriteError(w, 405, "Method not allowed")
+			return
+		}
+		record := scheduleRecord{}
+		if !apiReadBody(w, r, &record) {
+			return
+		}
+		s := scheduleFromRecord(record)
+		s.lastrun = ""
+		if err := checkSchedule(s); err != nil {
+			apiWriteError(w, 400, err.Error())
+			return
+		}
+		if err := apiScheduleAction(&s); err != nil {
+			apiWriteError(w, 400, err.Error())
+			return
+		}
+		scheduleMutex.Lock()
+		exists := getScheduleIndex(s.name) >= 0
+		if !exists {
+			schedules = append(schedules, s)
+			schedulesUnsaved = true
+		}
+		scheduleMutex.Unlock()
+		if exists {
+			apiWriteError(w, 409, "The schedule already exists: "+s.name)
+			return
+		}
+		SaveSchedulesToFile()
+		apiWriteJson(w, 201, scheduleToRecord(s))
+		return
+	}
+
+	name := parts[0]
+	scheduleMutex.Lock()
+	idx := getScheduleIndex(name)
+	s := getScheduleByIndex(idx)
+	scheduleMutex.Unlock()
+	if idx < 0 || s.oneshot {
+		apiWriteError(w, 404, "Unknown schedule: "+name)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		apiWriteJson(w, 200, scheduleToRecord(s))
+	case "PUT":
+		record := scheduleRecord{}
+		if !apiReadBody(w, r, &record) {
+			return
+		}
+		updated := scheduleFromRecord(record)
+		if updated.name == "" {
+			updated.name = name
+		}
+		updated.lastrun = s.lastrun
+		if err := checkSchedule(updated); err != nil {
+			apiWriteError(w, 400, err.Error())
+			return
+		}
+		if err := apiScheduleAction(&updated); err != nil {
+			apiWriteError(w, 400, err.Error())
+			return
+		}
+		scheduleMutex.Lock()
+		idx = getScheduleIndex(name)
+		conflict := updated.name != name && getScheduleIndex(updated.name) >= 0
+		if idx >= 0 && !conflict {
+			schedules[idx] = updated
+			schedulesUnsaved = true
+		}
+		scheduleMutex.Unlock()
+		if conflict {
+			apiWriteError(w, 409, "The schedule already exists: "+updated.name)
+			return
+		}
+		if idx < 0 {
+			apiWriteError(w, 404, "Unknown schedule: "+name)
+			return
+		}
+		SaveSchedulesToFile()
+		apiWriteJson(w, 200, scheduleToRecord(updated))
+	case "DELETE":
+		scheduleMutex.Lock()
+		idx = getScheduleIndex(name)
+		if idx >= 0 {
+			removeScheduleInLock(idx)
+		}
+		scheduleMutex.Unlock()
+		if idx < 0 {
+			apiWriteError(w, 404, "Unknown schedule: "+name)
+			return
+		}
+		SaveSchedulesToFile()
+		w.WriteHeader(204)
+	default:
+		apiWriteError(w, 405, "Method not allowed")
+	}
+}
+
+// GET state, GET, PUT state/<name>. The names are without the state. prefix.
+func apiState(w http.ResponseWriter, r *http.Request, parts []string) {
+	if len(parts) == 0 {
+		if r.Method != "GET" {
+			apiWriteError(w, 405, "Method not allowed")
+			return
+		}
+		snapshot := stateVariablesSnapshot()
+		names := []string{}
+		for n := range snapshot {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		list := []apiStateVariable{}
+		for _, n := range names {
+			list = append(list, apiStateVariable{Name: n, Value: snapshot[n]})
+		}
+		apiWriteJson(w, 200, list)
+		return
+	}
+
+	name := strings.TrimPrefix(strings.Join(parts, "/"), "state.")
+	if name == "" || strings.ContainsAny(name, " \t\n") {
+		apiWriteError(w, 400, "Wrong variable name: "+strconv.Quote(name))
+		return
+	}
+	switch r.Method {
+	case "GET":
+		value, ok := getStateVariable(name)
+		if !ok {
+			apiWriteError(w, 404, "Unknown state variable: "+name)
+			return
+		}
+		apiWriteJson(w, 200, apiStateVariable{Name: name, Value: value})
+	case "PUT":
+		v := apiStateVariable{}
+		if !apiReadBody(w, r, &v) {
+			return
+		}
+		oldValue := setStateVariable(name, v.Value)
+		AutomationStateVariableUpdate(name, oldValue, v.Value)
+		apiWriteJson(w, 200, apiStateVariable{Name: name, Value: v.Value})
+	default:
+		apiWriteError(w, 405, "Method not allowed")
+	}
+}
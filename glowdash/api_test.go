@@ -0,0 +1,165 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// A fake SMTherm device, it records the set commands and answers the queries
+type fakeSMTherm struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	commands []string
+}
+
+func startFakeSMTherm(t *testing.T) *fakeSMTherm {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %s", err)
+	}
+	f := &fakeSMTherm{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			buffer := make([]byte, 256)
+			n, _ := conn.Read(buffer)
+			command := string(buffer[:n])
+			if strings.HasPrefix(command, "cmd:qtt;") {
+				conn.Write([]byte(`{"working":"on","target_temp":21.5,"reference_temp":20.0,"heating_state":"off"}`))
+			} else {
+				f.mutex.Lock()
+				f.commands = append(f.commands, command)
+				f.mutex.Unlock()
+				conn.Write([]byte(`{"result":"ok"}`))
+			}
+			conn.Close()
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return f
+}
+
+func (f *fakeSMTherm) lastCommand() string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if len(f.commands) == 0 {
+		return ""
+	}
+	command := f.commands[len(f.commands)-1]
+	f.commands = nil
+	return command
+}
+
+func setupApiThermostat(t *testing.T) *fakeSMTherm {
+	t.Helper()
+	MaxLogLines = 10
+	GlowdashConsole.Init()
+	StateConfigDirectory = t.TempDir()
+	device := startFakeSMTherm(t)
+
+	th := NewPanelThermostat()
+	th.idStr = "th1"
+	th.deviceType = "smtherm"
+	th.hwDeviceIp = "127.0.0.1"
+	th.hwDevicePort = device.listener.Addr().(*net.TCPAddr).Port
+	savedPanels := Panels
+	Panels = []PanelInterface{th}
+	t.Cleanup(func() { Panels = savedPanels })
+	return device
+}
+
+func apiRequest(method string, path string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handleApi(w, r)
+	return w
+}
+
+func TestApiParameterizedPanelAction(t *testing.T) {
+	device := setupApiThermostat(t)
+	tests := []struct {
+		name    string
+		action  string
+		body    string
+		status  int
+		command string
+	}{
+		{"bool on", "work", `{"param":"on"}`, http.StatusOK, "cmd:stw;work:on;"},
+		{"bool off", "work", `{"param":"off"}`, http.StatusOK, "cmd:stw;work:off;"},
+		{"number", "tts", `{"param":"21.5"}`, http.StatusOK, "cmd:stt;ttemp:21.5;"},
+		{"number at the limit", "tts", `{"param":"30"}`, http.StatusOK, "cmd:stt;ttemp:30.0;"},
+		{"wrong bool", "work", `{"param":"maybe"}`, http.StatusBadRequest, ""},
+		{"number out of range", "tts", `{"param":"50"}`, http.StatusBadRequest, ""},
+		{"not a number", "tts", `{"param":"warm"}`, http.StatusBadRequest, ""},
+		{"NaN", "tts", `{"param":"NaN"}`, http.StatusBadRequest, ""},
+		{"missing parameter", "work", `{}`, http.StatusBadRequest, ""},
+		{"unknown action", "fly", `{}`, http.StatusBadRequest, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := apiRequest("POST", "/api/v1/panels/th1/actions/"+tc.action, tc.body)
+			if w.Code != tc.status {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tc.status, w.Body.String())
+			}
+			if got := device.lastCommand(); got != tc.command {
+				t.Errorf("the device got %q, want %q", got, tc.command)
+			}
+		})
+	}
+}
+
+func TestApiScheduleActionCheck(t *testing.T) {
+	setupApiThermostat(t)
+	savedSchedules := schedules
+	schedules = []Schedule{}
+	t.Cleanup(func() { schedules = savedSchedules })
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"bool action", `{"name":"a","hour":6,"ai":"th1","ap":"work/on"}`, http.StatusCreated},
+		{"number action", `{"name":"b","hour":6,"ai":"th1","ap":"tts/20"}`, http.StatusCreated},
+		{"older number format", `{"name":"c","hour":6,"ai":"th1","ap":"20"}`, http.StatusCreated},
+		{"end action", `{"name":"d","hour":6,"ai":"th1","ap":"work/on","duration":30,"endaction":"work/off"}`, http.StatusCreated},
+		{"wrong bool", `{"name":"e","hour":6,"ai":"th1","ap":"work/maybe"}`, http.StatusBadRequest},
+		{"number out of range", `{"name":"f","hour":6,"ai":"th1","ap":"tts/4"}`, http.StatusBadRequest},
+		{"missing parameter", `{"name":"g","hour":6,"ai":"th1","ap":"work"}`, http.StatusBadRequest},
+		{"wrong end action", `{"name":"h","hour":6,"ai":"th1","ap":"work/on","duration":30,"endaction":"tts/99"}`, http.StatusBadRequest},
+		{"unknown panel", `{"name":"i","hour":6,"ai":"nope","ap":"on"}`, http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := apiRequest("POST", "/api/v1/schedules", tc.body)
+			if w.Code != tc.status {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tc.status, w.Body.String())
+			}
+		})
+	}
+
+	if w := apiRequest("PUT", "/api/v1/schedules/a", `{"hour":7,"ai":"th1","ap":"tts/31"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("PUT with wrong parameter: got status %d, want 400", w.Code)
+	}
+	if w := apiRequest("DELETE", "/api/v1/schedules/a", ""); w.Code != http.StatusNoContent {
+		t.Errorf("DELETE: got status %d, want 204", w.Code)
+	}
+	if w := apiRequest("DELETE", "/api/v1/schedules/a", ""); w.Code != http.StatusNotFound {
+		t.Errorf("second DELETE: got status %d, want 404", w.Code)
+	}
+}
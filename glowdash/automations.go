@@ -0,0 +1,283 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyper-prog/smartyaml"
+)
+
+type AutomationTriggerTypes int
+
+const (
+	TriggerPanelState      AutomationTriggerTypes = 0
+	TriggerInput           AutomationTriggerTypes = 1
+	TriggerSensorThreshold AutomationTriggerTypes = 2
+	TriggerStateVariable   AutomationTriggerTypes = 3
+	TriggerTime            AutomationTriggerTypes = 4
+	TriggerHit             AutomationTriggerTypes = 5
+	TriggerUnknown         AutomationTriggerTypes = 99
+)
+
+// Event driven rule: when the trigger fires and all conditions are true the program runs in background
+type Automation struct {
+	name        string
+	enabled     bool
+	triggerType AutomationTriggerTypes
+	panelId     string
+	fromValue   string
+	toValue     string
+	sensor      string
+	quantity    string
+	hasAbove    bool
+	above       float64
+	hasBelow    bool
+	below       float64
+	variable    string
+	timePattern string
+	ip          string
+	conditions  []string
+	program     string
+}
+
+var Automations []Automation = []Automation{}
+
+var automationTriggerNames = map[string]AutomationTriggerTypes{
+	"PanelState":      TriggerPanelState,
+	"Input":           TriggerInput,
+	"SensorThreshold": TriggerSensorThreshold,
+	"StateVariable":   TriggerStateVariable,
+	"Time":            TriggerTime,
+	"Hit":             TriggerHit,
+}
+
+func ReadAutomationsConfig(sy smartyaml.SmartYAML) {
+	Automations = []Automation{}
+	if !sy.NodeExists("/GlowDash/Automations") {
+		return
+	}
+	adefs, _ := sy.GetArrayByPath("/GlowDash/Automations")
+	al := len(adefs)
+	for i := 0; i < al; i++ {
+		base := fmt.Sprintf("/GlowDash/Automations/[%d]", i)
+		a := Automation{}
+		a.name = sy.GetStringByPathWithDefault(base+"/Name", fmt.Sprintf("Automation %d", i+1))
+		a.enabled = sy.GetStringByPathWithDefault(base+"/Enabled", "yes") != "no"
+		a.program = sy.GetStringByPathWithDefault(base+"/Program", "")
+
+		typ := sy.GetStringByPathWithDefault(base+"/Trigger/Type", "")
+		tt, ok := automationTriggerNames[typ]
+		if !ok {
+			log.Printf("Error, unknown trigger type \"%s\" in automation \"%s\"\n", typ, a.name)
+			continue
+		}
+		a.triggerType = tt
+		a.panelId = sy.GetStringByPathWithDefault(base+"/Trigger/PanelId", "")
+		a.fromValue = sy.GetStringByPathWithDefault(base+"/Trigger/From", "")
+		a.toValue = sy.GetStringByPathWithDefault(base+"/Trigger/To", "")
+		a.sensor = sy.GetStringByPathWithDefault(base+"/Trigger/Sensor", "")
+		a.quantity = sy.GetStringByPathWithDefault(base+"/Trigger/Value", "Temp")
+		if sy.NodeExists(base + "/Trigger/Above") {
+			a.hasAbove = true
+			a.above = sy.GetFloat64ByPathWithDefault(base+"/Trigger/Above", 0.0)
+		}
+		if sy.NodeExists(base + "/Trigger/Below") {
+			a.hasBelow = true
+			a.below = sy.GetFloat64ByPathWithDefault(base+"/Trigger/Below", 0.0)
+		}
+		a.variable = strings.TrimPrefix(sy.GetStringByPathWithDefault(base+"/Trigger/Variable", ""), "state.")
+		a.timePattern = sy.GetStringByPathWithDefault(base+"/Trigger/At", "")
+		a.ip = sy.GetStringByPathWithDefault(base+"/Trigger/Ip", "")
+
+		if sy.NodeExists(base + "/Conditions") {
+			cdefs, _ := sy.GetArrayByPath(base + "/Conditions")
+			for j := 0; j < len(cdefs); j++ {
+				c := sy.GetStringByPathWithDefault(fmt.Sprintf("%s/Conditions/[%d]", base, j), "")
+				if c != "" {
+					a.conditions = append(a.conditions, c)
+				}
+			}
+		}
+		Automations = append(Automations, a)
+	}
+}
+
+// Normalizes the on/off like values to compare with panel states
+func automationStateValue(v string) string {
+	lv := strings.ToLower(strings.TrimSpace(v))
+	if lv == "on" || lv == "true" || lv == "yes" || lv == "open" {
+		return "1"
+	}
+	if lv == "off" || lv == "false" || lv == "no" || lv == "closed" {
+		return "0"
+	}
+	return lv
+}
+
+func automationValueMatch(required string, value string) bool {
+	if required == "" || required == "*" || required == "any" {
+		return true
+	}
+	return automationStateValue(required) == automationStateValue(value)
+}
+
+// Matches one part of the time pattern: "*", "N", "*/N" or comma separated list of numbers
+func timePatternPartMatch(pattern string, value int) bool {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "*" {
+		return true
+	}
+	if strings.HasPrefix(pattern, "*/") {
+		step, err := strconv.Atoi(pattern[2:])
+		return err == nil && step > 0 && value%step == 0
+	}
+	for _, p := range strings.Split(pattern, ",") {
+		if n, err := strconv.Atoi(strings.TrimSpace(p)); err == nil && n == value {
+			return true
+		}
+	}
+	return false
+}
+
+func timePatternMatch(pattern string, t time.Time) bool {
+	parts := strings.Split(pattern, ":")
+	if len(parts) != 2 {
+		return false
+	}
+	return timePatternPartMatch(parts[0], t.Hour()) && timePatternPartMatch(parts[1], t.Minute())
+}
+
+func fireAutomation(a Automation, triggerVariables map[string]string) {
+	variables := map[string]string{}
+	for n, v := range triggerVariables {
+		variables[n] = v
+	}
+	variables["Automation.Name"] = a.name
+
+	if len(a.conditions) > 0 {
+		ctx := RunContext{variables: variables}
+		AddBaseVariables(&ctx)
+		for _, c := range a.conditions {
+			if !EvalExpressionBool(ctx, c) {
+				if DebugLevel > 2 {
+					fmt.Printf("Automation \"%s\" triggered but the condition is false: %s\n", a.name, c)
+				}
+				return
+			}
+		}
+	}
+
+	code, found := getLibraryProgram(a.program)
+	if !found {
+		GlowdashConsole.Write(T("ERROR: Automation \"{{name}}\" triggered but the program \"{{program}}\" is not found",
+			map[string]any{"name": a.name, "program": a.program}))
+		return
+	}
+	GlowdashConsole.Write(T("Automation \"{{name}}\" triggered, run \"{{program}}\"", map[string]any{"name": a.name, "program": a.program}))
+	startBackgroundProgram(code, variables, LibraryProgramRunOptions(a.program), nil)
+}
+
+// Called by the panels when a new hardware state is received
+func AutomationPanelStateUpdate(panelId string, hadValidInfo bool, oldState int, newState int, oldInput int, newInput int) {
+	if hadValidInfo && len(PresencePanels) > 0 {
+		PresenceRecordStateChange(panelId, oldState, newState)
+	}
+	if !hadValidInfo || len(Automations) == 0 {
+		return
+	}
+	for _, a := range Automations {
+		if !a.enabled || a.panelId != panelId {
+			continue
+		}
+		var oldValue, newValue int
+		if a.triggerType == TriggerPanelState {
+			oldValue, newValue = oldState, newState
+		} else if a.triggerType == TriggerInput {
+			oldValue, newValue = oldInput, newInput
+		} else {
+			continue
+		}
+		if oldValue == newValue {
+			continue
+		}
+		ov := fmt.Sprintf("%d", oldValue)
+		nv := fmt.Sprintf("%d", newValue)
+		if automationValueMatch(a.fromValue, ov) && automationValueMatch(a.toValue, nv) {
+			typeName := "PanelState"
+			if a.triggerType == TriggerInput {
+				typeName = "Input"
+			}
+			fireAutomation(a, map[string]string{"Trigger.Type": typeName, "Trigger.PanelId": panelId,
+				"Trigger.OldValue": ov, "Trigger.NewValue": nv})
+		}
+	}
+}
+
+// Called by the sensor panels on every new measured value. The crossing is not checked until the sensor
+// has a previous measured value (hadValue), so the first values after the start do not fire the automations.
+func AutomationSensorUpdate(panelId string, sensor string, quantity string, hadValue bool, oldValue float64, newValue float64) {
+	if !hadValue || len(Automations) == 0 {
+		return
+	}
+	for _, a := range Automations {
+		if !a.enabled || a.triggerType != TriggerSensorThreshold || a.panelId != panelId ||
+			a.sensor != sensor || !strings.EqualFold(a.quantity, quantity) {
+			continue
+		}
+		crossed := false
+		if a.hasAbove && oldValue <= a.above && newValue > a.above {
+			crossed = true
+		}
+		if a.hasBelow && oldValue >= a.below && newValue < a.below {
+			crossed = true
+		}
+		if crossed {
+			fireAutomation(a, map[string]string{"Trigger.Type": "SensorThreshold", "Trigger.PanelId": panelId,
+				"Trigger.Sensor": sensor, "Trigger.OldValue": fmt.Sprintf("%g", oldValue), "Trigger.NewValue": fmt.Sprintf("%g", newValue)})
+		}
+	}
+}
+
+// Called when a state. variable is changed (name without the state. prefix)
+func AutomationStateVariableUpdate(name string, oldValue string, newValue string) {
+	if oldValue == newValue || len(Automations) == 0 {
+		return
+	}
+	for _, a := range Automations {
+		if !a.enabled || a.triggerType != TriggerStateVariable || a.variable != name {
+			continue
+		}
+		if automationValueMatch(a.fromValue, oldValue) && automationValueMatch(a.toValue, newValue) {
+			fireAutomation(a, map[string]string{"Trigger.Type": "StateVariable", "Trigger.Variable": "state." + name,
+				"Trigger.OldValue": oldValue, "Trigger.NewValue": newValue})
+		}
+	}
+}
+
+// Called by the scheduler once in every minute
+func AutomationTimeTick(t time.Time) {
+	for _, a := range Automations {
+		if a.enabled && a.triggerType == TriggerTime && timePatternMatch(a.timePattern, t) {
+			fireAutomation(a, map[string]string{"Trigger.Type": "Time", "Trigger.Time": fmt.Sprintf("%02d:%02d", t.Hour(), t.Minute())})
+		}
+	}
+}
+
+// Called when a device sends a /hit request
+func AutomationHit(ip string) {
+	for _, a := range Automations {
+		if a.enabled && a.triggerType == TriggerHit && (a.ip == "" || a.ip == ip) {
+			fireAutomation(a, map[string]string{"Trigger.Type": "Hit", "Trigger.Ip": ip})
+		}
+	}
+}
@@ -10,7 +10,7 @@ package main
 import (
 	"fmt"
 	"net/http"
-	"time"
+	"sync"
 
 	"github.com/hyper-prog/smartyaml"
 )
@@ -18,6 +18,7 @@ import (
 type GlowdashConsoleDataType struct {
 	lines []string
 	pos   int
+	mutex sync.Mutex
 }
 
 var GlowdashConsole GlowdashConsoleDataType
@@ -53,9 +54,11 @@ func (c *GlowdashConsoleDataType) Write(s string) {
 	if MaxLogLines <= 0 {
 		return
 	}
-	ct := time.Now()
+	ct := Now()
 	ll := fmt.Sprintf("&lt;%d-%02d-%02d %02d:%02d:%02d&gt; %s", ct.Year(), ct.Month(), ct.Day(), ct.Hour(), ct.Minute(), ct.Second(), s)
 
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	c.lines[c.pos] = ll
 	c.pos++
 	if c.pos >= MaxLogLines {
@@ -63,6 +66,23 @@ func (c *GlowdashConsoleDataType) Write(s string) {
 	}
 }
 
+// Returns the non empty lines, the newest first
+func (c *GlowdashConsoleDataType) Lines() []string {
+	list := []string{}
+	if MaxLogLines <= 0 {
+		return list
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i := MaxLogLines - 1; i >= 0; i-- {
+		ll := c.lines[(c.pos+i)%MaxLogLines]
+		if len(ll) > 0 {
+			list = append(list, ll)
+		}
+	}
+	return list
+}
+
 func (p *PageConsole) LoadCustomConfig(sy smartyaml.SmartYAML, indexInConfig int) {
 
 }
@@ -70,13 +90,8 @@ func (p *PageConsole) LoadCustomConfig(sy smartyaml.SmartYAML, indexInConfig int
 func (p PageConsole) PageHtml(withContainer bool, r *http.Request) string {
 	html := "<div class=\"logpage-inner-show\">"
 
-	if MaxLogLines > 0 {
-		for i := MaxLogLines - 1; i >= 0; i-- {
-			ll := GlowdashConsole.lines[(GlowdashConsole.pos+i)%MaxLogLines]
-			if len(ll) > 0 {
-				html += ll + "<br/>"
-			}
-		}
+	for _, ll := range GlowdashConsole.Lines() {
+		html += ll + "<br/>"
 	}
 	html += "</div>"
 
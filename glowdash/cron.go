@@ -0,0 +1,132 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parsed cron expression: minute hour day-of-month month day-of-week
+type CronExpr struct {
+	minutes  []bool
+	hours    []bool
+	days     []bool
+	months   []bool
+	weekdays []bool
+
+	anyDay     bool
+	anyWeekday bool
+}
+
+// Parses one field of the cron expression: *, */n, a, a-b, a-b/n and the comma separated lists of these
+func parseCronField(field string, min int, max int) ([]bool, bool, error) {
+	values := make([]bool, max+1)
+	any := field == "*"
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		rangePart := part
+		if i := strings.Index(part, "/"); i >= 0 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s < 1 {
+				return values, false, fmt.Errorf("wrong step in %s", part)
+			}
+			step = s
+			rangePart = part[:i]
+		}
+
+		from := min
+		to := max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			f, err := strconv.Atoi(bounds[0])
+			if err != nil {
+				return values, false, fmt.Errorf("wrong value %s", part)
+			}
+			from = f
+			to = f
+			if len(bounds) == 2 {
+				t, err := strconv.Atoi(bounds[1])
+				if err != nil {
+					return values, false, fmt.Errorf("wrong value %s", part)
+				}
+				to = t
+			} else if step > 1 {
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return values, false, fmt.Errorf("value out of range (%d-%d) in %s", min, max, part)
+		}
+		for v := from; v <= to; v += step {
+			values[v] = true
+		}
+	}
+	return values, any, nil
+}
+
+// Parses a standard 5 field cron expression. The day of week is 0-7 (0 and 7 is Sunday).
+func ParseCronExpr(expr string) (CronExpr, error) {
+	c := CronExpr{}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return c, fmt.Errorf("the cron expression must have 5 fields: %s", expr)
+	}
+	var err error
+	if c.minutes, _, err = parseCronField(fields[0], 0, 59); err != nil {
+		return c, err
+	}
+	if c.hours, _, err = parseCronField(fields[1], 0, 23); err != nil {
+		return c, err
+	}
+	if c.days, c.anyDay, err = parseCronField(fields[2], 1, 31); err != nil {
+		return c, err
+	}
+	if c.months, _, err = parseCronField(fields[3], 1, 12); err != nil {
+		return c, err
+	}
+	if c.weekdays, c.anyWeekday, err = parseCronField(fields[4], 0, 7); err != nil {
+		return c, err
+	}
+	if c.weekdays[7] {
+		c.weekdays[0] = true
+	}
+	return c, nil
+}
+
+// Checks if the expression matches every hour of the day (the hour field is * or covers all hours)
+func (c CronExpr) EveryHour() bool {
+	for _, h := range c.hours {
+		if !h {
+			return false
+		}
+	}
+	return true
+}
+
+// Checks if the minute of the given time matches the expression. If both the day of month and the
+// day of week are restricted, one of them have to match (like the classic cron).
+func (c CronExpr) Matches(t time.Time) bool {
+	if !c.minutes[t.Minute()] || !c.hours[t.Hour()] || !c.months[int(t.Month())] {
+		return false
+	}
+	dayMatch := c.days[t.Day()]
+	weekdayMatch := c.weekdays[int(t.Weekday())]
+	if c.anyDay && c.anyWeekday {
+		return true
+	}
+	if c.anyDay {
+		return weekdayMatch
+	}
+	if c.anyWeekday {
+		return dayMatch
+	}
+	return dayMatch || weekdayMatch
+}
@@ -0,0 +1,175 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronField(t *testing.T) {
+	tests := []struct {
+		name   string
+		field  string
+		min    int
+		max    int
+		values []int
+		any    bool
+		fail   bool
+	}{
+		{"any", "*", 0, 5, []int{0, 1, 2, 3, 4, 5}, true, false},
+		{"single value", "3", 0, 5, []int{3}, false, false},
+		{"range", "2-4", 0, 5, []int{2, 3, 4}, false, false},
+		{"list", "1,3,5", 0, 5, []int{1, 3, 5}, false, false},
+		{"step of any", "*/15", 0, 59, []int{0, 15, 30, 45}, false, false},
+		{"step of range", "5-10/2", 0, 59, []int{5, 7, 9}, false, false},
+		{"step from a value", "50/4", 0, 59, []int{50, 54, 58}, false, false},
+		{"list of ranges", "1-2,4-5", 0, 5, []int{1, 2, 4, 5}, false, false},
+		{"lowest and highest", "1,31", 1, 31, []int{1, 31}, false, false},
+		{"below the minimum", "0", 1, 31, nil, false, true},
+		{"above the maximum", "60", 0, 59, nil, false, true},
+		{"reversed range", "5-3", 0, 59, nil, false, true},
+		{"zero step", "*/0", 0, 59, nil, false, true},
+		{"wrong step", "*/x", 0, 59, nil, false, true},
+		{"not a number", "a", 0, 59, nil, false, true},
+		{"open range", "2-", 0, 59, nil, false, true},
+		{"empty list item", "1,,2", 0, 59, nil, false, true},
+		{"empty field", "", 0, 59, nil, false, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			values, any, err := parseCronField(tc.field, tc.min, tc.max)
+			if tc.fail {
+				if err == nil {
+					t.Fatalf("%q is accepted", tc.field)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%q is refused: %s", tc.field, err)
+			}
+			if any != tc.any {
+				t.Errorf("any is %v, want %v", any, tc.any)
+			}
+			want := map[int]bool{}
+			for _, v := range tc.values {
+				want[v] = true
+			}
+			for v := tc.min; v <= tc.max; v++ {
+				if values[v] != want[v] {
+					t.Errorf("value %d is %v, want %v", v, values[v], want[v])
+				}
+			}
+		})
+	}
+}
+
+func TestParseCronExpr(t *testing.T) {
+	tests := []struct {
+		name      string
+		expr      string
+		fail      bool
+		everyHour bool
+	}{
+		{"every minute", "* * * * *", false, true},
+		{"daily", "30 7 * * *", false, false},
+		{"every hour by range", "0 0-23 * * *", false, true},
+		{"sunday as 7", "0 8 * * 7", false, false},
+		{"extra spaces", " 0  8 * *  1-5 ", false, false},
+		{"four fields", "0 8 * *", true, false},
+		{"six fields", "0 0 8 * * *", true, false},
+		{"empty", "", true, false},
+		{"wrong minute", "60 8 * * *", true, false},
+		{"wrong hour", "0 24 * * *", true, false},
+		{"wrong day", "0 8 0 * *", true, false},
+		{"wrong month", "0 8 * 13 *", true, false},
+		{"wrong weekday", "0 8 * * 8", true, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := ParseCronExpr(tc.expr)
+			if tc.fail {
+				if err == nil {
+					t.Fatalf("%q is accepted", tc.expr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%q is refused: %s", tc.expr, err)
+			}
+			if c.EveryHour() != tc.everyHour {
+				t.Errorf("EveryHour is %v, want %v", c.EveryHour(), tc.everyHour)
+			}
+		})
+	}
+}
+
+func TestCronExprMatches(t *testing.T) {
+	// 2026-06-01 is a Monday, 2026-06-07 is a Sunday
+	tests := []struct {
+		name  string
+		expr  string
+		t     time.Time
+		match bool
+	}{
+		{"daily", "30 7 * * *", time.Date(2026, 6, 3, 7, 30, 0, 0, time.UTC), true},
+		{"daily, other minute", "30 7 * * *", time.Date(2026, 6, 3, 7, 31, 0, 0, time.UTC), false},
+		{"step", "*/20 * * * *", time.Date(2026, 6, 3, 11, 40, 0, 0, time.UTC), true},
+		{"step, other minute", "*/20 * * * *", time.Date(2026, 6, 3, 11, 50, 0, 0, time.UTC), false},
+		{"sunday as 0", "0 8 * * 0", time.Date(2026, 6, 7, 8, 0, 0, 0, time.UTC), true},
+		{"sunday as 7", "0 8 * * 7", time.Date(2026, 6, 7, 8, 0, 0, 0, time.UTC), true},
+		{"weekdays on sunday", "0 8 * * 1-5", time.Date(2026, 6, 7, 8, 0, 0, 0, time.UTC), false},
+		{"month", "0 8 * 6 *", time.Date(2026, 6, 3, 8, 0, 0, 0, time.UTC), true},
+		{"other month", "0 8 * 7 *", time.Date(2026, 6, 3, 8, 0, 0, 0, time.UTC), false},
+		{"day and weekday, day matches", "0 8 3 * 1", time.Date(2026, 6, 3, 8, 0, 0, 0, time.UTC), true},
+		{"day and weekday, weekday matches", "0 8 3 * 1", time.Date(2026, 6, 1, 8, 0, 0, 0, time.UTC), true},
+		{"day and weekday, none matches", "0 8 3 * 1", time.Date(2026, 6, 2, 8, 0, 0, 0, time.UTC), false},
+		{"only day restricted", "0 8 3 * *", time.Date(2026, 6, 1, 8, 0, 0, 0, time.UTC), false},
+		{"only weekday restricted", "0 8 * * 1", time.Date(2026, 6, 3, 8, 0, 0, 0, time.UTC), false},
+		{"last day", "0 0 31 * *", time.Date(2026, 7, 31, 0, 0, 0, 0, time.UTC), true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := ParseCronExpr(tc.expr)
+			if err != nil {
+				t.Fatalf("%q is refused: %s", tc.expr, err)
+			}
+			if got := c.Matches(tc.t); got != tc.match {
+				t.Errorf("%q at %s: got %v, want %v", tc.expr, tc.t.Format("2006-01-02 15:04"), got, tc.match)
+			}
+		})
+	}
+}
+
+func TestIntervalScheduleDue(t *testing.T) {
+	s := nullSchedule()
+	s.kind = "interval"
+	s.interval = 45
+	s.hour = 8
+	s.min = 30
+	s.dayMon = true
+	tests := []struct {
+		name string
+		t    time.Time
+		due  bool
+	}{
+		{"start time", time.Date(2026, 6, 1, 8, 30, 0, 0, time.UTC), true},
+		{"first repeat", time.Date(2026, 6, 1, 9, 15, 0, 0, time.UTC), true},
+		{"between repeats", time.Date(2026, 6, 1, 9, 0, 0, 0, time.UTC), false},
+		{"before the start", time.Date(2026, 6, 1, 7, 45, 0, 0, time.UTC), false},
+		{"last repeat of the day", time.Date(2026, 6, 1, 23, 30, 0, 0, time.UTC), true},
+		{"disabled day", time.Date(2026, 6, 2, 8, 30, 0, 0, time.UTC), false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := scheduleDueNominal(s, tc.t); got != tc.due {
+				t.Errorf("at %s: got %v, want %v", tc.t.Format("2006-01-02 15:04"), got, tc.due)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/hyper-prog/smartjson"
+)
+
+// Mock value of a device or http call in dry run mode.
+// The target is "http:<url>", "shelly:<host[:port]>/<inDeviceId>" or "modbus:<host:port>/<unitId>/<address>",
+// a trailing * matches any ending. The value FAIL makes the call fail.
+type DryRunMock struct {
+	target string
+	value  string
+}
+
+// Dry run (simulation) of a program: the device, http, schedule and background commands are recorded
+// to the trace instead of executed, the state. variables are changed only in the dry run.
+type DryRun struct {
+	mocks []DryRunMock
+	state map[string]string
+	trace []string
+	mutex sync.Mutex
+}
+
+var DryRunMocks []DryRunMock = []DryRunMock{}
+
+// Creates a dry run, the given mocks are checked before the mocks of the config
+func NewDryRun(mocks []DryRunMock) *DryRun {
+	dr := &DryRun{
+		mocks: []DryRunMock{},
+		state: map[string]string{},
+		trace: []string{},
+	}
+	dr.mocks = append(dr.mocks, mocks...)
+	dr.mocks = append(dr.mocks, DryRunMocks...)
+	return dr
+}
+
+// Parses a mock definition in "<target> = <value>" format
+func ParseDryRunMock(line string) (DryRunMock, bool) {
+	parts := strings.SplitN(line, " = ", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+		return DryRunMock{}, false
+	}
+	return DryRunMock{target: strings.TrimSpace(parts[0]), value: strings.TrimSpace(parts[1])}, true
+}
+
+// Parses the mock definitions, one per line. Empty lines and lines starting with // are skipped.
+func ParseDryRunMocks(text string) []DryRunMock {
+	mocks := []DryRunMock{}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+		if m, ok := ParseDryRunMock(line); ok {
+			mocks = append(mocks, m)
+		}
+	}
+	return mocks
+}
+
+// Parses the variables in "name=value" format, one per line
+func ParseDryRunVariables(text string) map[string]string {
+	variables := map[string]string{}
+	for _, line := range strings.Split(text, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) != "" {
+			variables[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	return variables
+}
+
+// Executes the program in dry run mode
+func ExecuteCommandsDryRun(program string, contextVariables map[string]string, options ProgramRunOptions, dr *DryRun) map[string]string {
+	options.dryRun = dr
+	relatedPanels := []string{}
+	return ExecuteCommandsWithOptions(program, contextVariables, &relatedPanels, options)
+}
+
+func (dr *DryRun) mockValue(target string, defaultValue string) string {
+	for _, m := range dr.mocks {
+		if strings.HasSuffix(m.target, "*") {
+			if strings.HasPrefix(target, m.target[:len(m.target)-1]) {
+				return m.value
+			}
+			continue
+		}
+		if m.target == target {
+			return m.value
+		}
+	}
+	return defaultValue
+}
+
+func (dr *DryRun) record(ctx *RunContext, text string) {
+	name := ctx.options.Name
+	if name == "" {
+		name = "-"
+	}
+	dr.mutex.Lock()
+	defer dr.mutex.Unlock()
+	dr.trace = append(dr.trace, fmt.Sprintf("%s:%d %s", name, ctx.ip+1, text))
+}
+
+func (dr *DryRun) Trace() []string {
+	dr.mutex.Lock()
+	defer dr.mutex.Unlock()
+	return append([]string{}, dr.trace...)
+}
+
+func (dr *DryRun) getStateVariable(name string) (string, bool) {
+	dr.mutex.Lock()
+	v, ok := dr.state[name]
+	dr.mutex.Unlock()
+	if ok {
+		return v, true
+	}
+	return getStateVariable(name)
+}
+
+func (dr *DryRun) setStateVariable(name string, value string) {
+	dr.mutex.Lock()
+	defer dr.mutex.Unlock()
+	dr.state[name] = value
+}
+
+func (dr *DryRun) stateVariablesSnapshot() map[string]string {
+	snapshot := stateVariablesSnapshot()
+	dr.mutex.Lock()
+	defer dr.mutex.Unlock()
+	for n, v := range dr.state {
+		snapshot[n] = v
+	}
+	return snapshot
+}
+
+// The state variable accessors used by the program execution, they use the dry run copy if required
+
+func ctxGetStateVariable(ctx *RunContext, name string) (string, bool) {
+	if ctx.options.dryRun != nil {
+		return ctx.options.dryRun.getStateVariable(name)
+	}
+	return getStateVariable(name)
+}
+
+func ctxStateVariablesSnapshot(ctx *RunContext) map[string]string {
+	if ctx.options.dryRun != nil {
+		return ctx.options.dryRun.stateVariablesSnapshot()
+	}
+	return stateVariablesSnapshot()
+}
+
+// Executes or simulates a json http query
+func ctxJsonHttpQuery(ctx *RunContext, command string, url string) JsonHttpQuery {
+	dr := ctx.options.dryRun
+	if dr == nil {
+		return execJsonHttpQuery(url)
+	}
+	value := dr.mockValue("http:"+url, "{}")
+	dr.record(ctx, fmt.Sprintf("%s GET %s => %s", command, url, value))
+	if value == "FAIL" {
+		return JsonHttpQuery{Success: false, ErrorMessage: "Simulated failure", QueryUrl: url}
+	}
+	sj, err := smartjson.ParseJSON([]byte(value))
+	if err != nil {
+		return JsonHttpQuery{Success: false, ErrorMessage: "Json parse error: " + err.Error(), QueryUrl: url}
+	}
+	return JsonHttpQuery{Success: true, ErrorMessage: "", QueryUrl: url, SmartJSON: sj}
+}
+
+// Executes or simulates a http request
+func ctxHttpRequest(ctx *RunContext, spec HttpRequestSpec) HttpQueryResult {
+	dr := ctx.options.dryRun
+	if dr == nil {
+		return execHttpRequest(spec)
+	}
+	value := dr.mockValue("http:"+spec.Url, "")
+	text := fmt.Sprintf("CallHttpEx %s %s", spec.Method, spec.Url)
+	if spec.Body != "" {
+		text += " body: " + spec.Body
+	}
+	dr.record(ctx, text+" => "+value)
+	if value == "FAIL" {
+		return HttpQueryResult{Success: false, ErrorMessage: "Simulated failure", StatusCode: 0, Body: []byte{}, Headers: http.Header{}}
+	}
+	return HttpQueryResult{Success: true, ErrorMessage: "", StatusCode: 200, Body: []byte(value), Headers: http.Header{}}
+}
+
+// Simulates the ShellyRelay command, the parts are already resolved and checked
+func dryRunShellyRelay(ctx *RunContext, parts []string) {
+	dr := ctx.options.dryRun
+	target := "shelly:" + parts[1] + "/" + parts[3]
+	defaultValue := "ok"
+	if parts[2] == "readrelay" {
+		defaultValue = "false"
+	}
+	if parts[2] == "readcover" {
+		defaultValue = "0"
+	}
+	value := dr.mockValue(target, defaultValue)
+	dr.record(ctx, fmt.Sprintf("ShellyRelay %s %s %s %s => %s", parts[0], parts[1], parts[2], parts[3], value))
+	if value == "FAIL" {
+		callFailed(ctx, "LastShellyRelayCallSuccess", "ShellyRelay call failed (simulated): "+strings.Join(parts, " "))
+		return
+	}
+	if parts[2] == "readrelay" {
+		state := EvalExpressionBoolSv(*ctx, []string{value})
+		ctx.variables[parts[0]] = fmt.Sprintf("%t", state)
+		ctx.variables[parts[0]+".StateInt"] = fmt.Sprintf("%d", IntFromBool(state))
+		ctx.variables[parts[0]+".StateBool"] = fmt.Sprintf("%t", state)
+		ctx.variables[parts[0]+".InputState"] = "false"
+	}
+	if parts[2] == "readcover" {
+		ctx.variables[parts[0]] = value
+		ctx.variables[parts[0]+".Position"] = value
+		ctx.variables[parts[0]+".NamedState"] = "stopped"
+	}
+	ctx.variables["LastShellyRelayCallSuccess"] = "true"
+}
+
+// Simulates the ModbusTcp command, the parts are already resolved and checked
+func dryRunModbusTcp(ctx *RunContext, parts []string) {
+	dr := ctx.options.dryRun
+	target := "modbus:" + parts[1] + "/" + parts[2] + "/" + parts[4]
+	defaultValue := "ok"
+	if parts[3] == "readcoil" {
+		defaultValue = "false"
+	}
+	if parts[3] == "readinput" {
+		defaultValue = "0"
+	}
+	value := dr.mockValue(target, defaultValue)
+	dr.record(ctx, fmt.Sprintf("ModbusTcp %s %s %s %s %s => %s", parts[0], parts[1], parts[2], parts[3], parts[4], value))
+	if value == "FAIL" {
+		callFailed(ctx, "LastModbusTcpCallSuccess", "ModbusTcp call failed (simulated): "+strings.Join(parts, " "))
+		return
+	}
+	if parts[3] == "readcoil" {
+		ctx.variables[parts[0]] = fmt.Sprintf("%t", EvalExpressionBoolSv(*ctx, []string{value}))
+	}
+	if parts[3] == "readinput" {
+		ctx.variables[parts[0]] = value
+	}
+	ctx.variables["LastModbusTcpCallSuccess"] = "true"
+}
+
+// Records the command in dry run mode. Returns true if the command must not be executed.
+func dryRunSkip(ctx *RunContext, text string) bool {
+	if ctx.options.dryRun == nil {
+		return false
+	}
+	ctx.options.dryRun.record(ctx, text+" (not executed)")
+	return true
+}
+
+// Command line dry run: glowdash dryrun [-mock "<target> = <value>"]... <config.yml> <program> [name=value]...
+// Prints the trace and the results, returns the exit code.
+func runDryRunCommand(args []string) int {
+	mocks := []DryRunMock{}
+	for len(args) >= 2 && args[0] == "-mock" {
+		mock, ok := ParseDryRunMock(args[1])
+		if !ok {
+			fmt.Printf("Error: Wrong mock definition: %s\n", args[1])
+			return 2
+		}
+		mocks = append(mocks, mock)
+		args = args[2:]
+	}
+	if len(args) < 2 {
+		fmt.Println("Usage: glowdash dryrun [-mock \"<target> = <value>\"]... <config.yml> <program> [name=value]...")
+		return 2
+	}
+
+	configFileName = args[0]
+	StaticFilesDirectory = ""
+	readConfig(configFileName)
+	GlowdashConsole.Init()
+	ReadStateVariablesFromFile()
+
+	code, found := getLibraryProgram(args[1])
+	if !found {
+		fmt.Printf("Error: Unknown program: %s\n", args[1])
+		return 2
+	}
+
+	dr := NewDryRun(mocks)
+	results := ExecuteCommandsDryRun(code, ParseDryRunVariables(strings.Join(args[2:], "\n")), LibraryProgramRunOptions(args[1]), dr)
+	for _, line := range dr.Trace() {
+		fmt.Println(line)
+	}
+	fmt.Printf("Return: %s\n", results["Return"])
+	if results["Error"] != "" {
+		fmt.Printf("Error: %s\n", results["Error"])
+		return 1
+	}
+	return 0
+}
@@ -72,9 +72,10 @@ func (d DeviceTypeCustom) SwitchTo(p DeviceHardwareInterface, toState bool, from
 			map[string]any{"title": p.EventTitle(), "code": d.customsetcode, "state": T(initVariables["RequiredStateText"])}))
 	}
 
-	code, ok := ProgramLibrary[d.customsetcode]
+	code, ok := getLibraryProgram(d.customsetcode)
 	if !ok {
 		sr.ok = false
+		sr.err = fmt.Errorf("not found custom set code: %s", d.customsetcode)
 		GlowdashConsole.Write(T("ERROR: The last operation failed to complete"))
 		p.InvalidateInfo()
 		if DebugLevel >= 1 {
@@ -83,12 +84,13 @@ func (d DeviceTypeCustom) SwitchTo(p DeviceHardwareInterface, toState bool, from
 		return sr
 	}
 
-	results := ExecuteCommands(code, initVariables, &relatedPanels)
+	results := ExecuteCommandsWithOptions(code, initVariables, &relatedPanels, LibraryProgramRunOptions(d.customsetcode))
 	if DebugLevel >= 2 {
 		fmt.Printf("Custom set code \"%s\" executed for panel \"%s\", result: %s\n", d.customsetcode, p.EventTitle(), results["Return"])
 	}
-	if results["Return"] == "error" {
+	if results["Return"] == "error" || results["Aborted"] == "true" {
 		sr.ok = false
+		sr.err = fmt.Errorf("the custom set code %s returned error", d.customsetcode)
 		GlowdashConsole.Write(T("ERROR: The last operation failed to complete"))
 		p.InvalidateInfo()
 		return sr
@@ -114,7 +116,7 @@ func (d DeviceTypeCustom) QuerySwitch(p DeviceHardwareInterface, from string) Sw
 		voltage:       0.0,
 	}
 
-	code, ok := ProgramLibrary[d.customquerycode]
+	code, ok := getLibraryProgram(d.customquerycode)
 	if !ok {
 		qr.ok = false
 		p.InvalidateInfo()
@@ -139,11 +141,11 @@ func (d DeviceTypeCustom) QuerySwitch(p DeviceHardwareInterface, from string) Sw
 	initVariables[baseNameStr+".DeviceType"] = p.DeviceType()
 	initVariables[baseNameStr+".ActionName"] = "update"
 
-	results := ExecuteCommands(code, initVariables, &relatedPanels)
+	results := ExecuteCommandsWithOptions(code, initVariables, &relatedPanels, LibraryProgramRunOptions(d.customquerycode))
 	if DebugLevel >= 2 {
 		fmt.Printf("Custom query code \"%s\" executed for panel \"%s\", result: %s\n", d.customquerycode, p.EventTitle(), results["Return"])
 	}
-	if results["Return"] == "error" {
+	if results["Return"] == "error" || results["Aborted"] == "true" {
 		qr.ok = false
 		p.InvalidateInfo()
 		return qr
@@ -31,6 +31,7 @@ func (d DeviceTypeModbusTCP) SwitchTo(p DeviceHardwareInterface, toState bool, f
 	if p.DeviceIp() == "" {
 		p.InvalidateInfo()
 		sr.ok = false
+		sr.err = errEmptyDeviceIp
 		if DebugLevel >= 1 {
 			fmt.Printf("Error: The modbus TCP device has empty IP address (panel \"%s\")\n", p.EventTitle())
 		}
@@ -64,6 +65,7 @@ func (d DeviceTypeModbusTCP) SwitchTo(p DeviceHardwareInterface, toState bool, f
 		GlowdashConsole.Write(T("ERROR: The last operation failed to complete"))
 		p.InvalidateInfo()
 		sr.ok = false
+		sr.err = err
 		if DebugLevel >= 1 {
 			fmt.Printf("Error while executing modbus TCP command on panel: \"%s\" (1)\n", p.EventTitle())
 		}
@@ -76,6 +78,7 @@ func (d DeviceTypeModbusTCP) SwitchTo(p DeviceHardwareInterface, toState bool, f
 		GlowdashConsole.Write(T("ERROR: The last operation failed to complete"))
 		p.InvalidateInfo()
 		sr.ok = false
+		sr.err = err2
 		if DebugLevel >= 1 {
 			fmt.Printf("Error while executing modbus TCP command on panel: \"%s\" (2)\n", p.EventTitle())
 		}
@@ -43,6 +43,7 @@ func (d DeviceTypeShelly) SwitchTo(p DeviceHardwareInterface, toState bool, from
 	if p.DeviceIp() == "" {
 		p.InvalidateInfo()
 		sr.ok = false
+		sr.err = errEmptyDeviceIp
 		if DebugLevel >= 1 {
 			fmt.Printf("Error: The Shelly device has empty IP address (panel %s)\n", p.EventTitle())
 		}
@@ -77,6 +78,7 @@ func (d DeviceTypeShelly) SwitchTo(p DeviceHardwareInterface, toState bool, from
 		GlowdashConsole.Write(T("ERROR: The last operation failed to complete"))
 		p.InvalidateInfo()
 		sr.ok = false
+		sr.err = ro.Err()
 		return sr
 	}
 
@@ -99,6 +101,7 @@ func (d DeviceTypeShelly) PerformThis(p DeviceHardwareInterface, fnc string, fro
 	if p.DeviceIp() == "" {
 		p.InvalidateInfo()
 		pr.ok = false
+		pr.err = errEmptyDeviceIp
 		if DebugLevel >= 1 {
 			fmt.Printf("Error: The Shelly device has empty IP address (panel %s)\n", p.EventTitle())
 		}
@@ -119,6 +122,7 @@ func (d DeviceTypeShelly) PerformThis(p DeviceHardwareInterface, fnc string, fro
 		if !ro.Success {
 			GlowdashConsole.Write(T("ERROR: The last operation failed to complete"))
 			pr.ok = false
+			pr.err = ro.Err()
 			p.InvalidateInfo()
 			return pr
 		}
@@ -143,6 +147,7 @@ func (d DeviceTypeShelly) PerformThis(p DeviceHardwareInterface, fnc string, fro
 		if !ro.Success {
 			GlowdashConsole.Write(T("ERROR: The last operation failed to complete"))
 			pr.ok = false
+			pr.err = ro.Err()
 			p.InvalidateInfo()
 			return pr
 		}
@@ -165,6 +170,7 @@ func (d DeviceTypeShelly) PerformThis(p DeviceHardwareInterface, fnc string, fro
 		if !ro.Success {
 			GlowdashConsole.Write(T("ERROR: The last operation failed to complete"))
 			pr.ok = false
+			pr.err = ro.Err()
 			p.InvalidateInfo()
 			return pr
 		}
@@ -193,12 +199,14 @@ func (d DeviceTypeShelly) ScriptTo(p DeviceHardwareInterface, scriptName string,
 
 	if toStr != "Start" && toStr != "Stop" {
 		pr.ok = false
+		pr.err = fmt.Errorf("unknown script function: %s", fnc)
 		return pr
 	}
 
 	if p.DeviceIp() == "" || scriptName == "" || p.InDeviceId() < 0 {
 		p.InvalidateInfo()
 		pr.ok = false
+		pr.err = errMissingDeviceConfig
 		if DebugLevel >= 1 {
 			fmt.Printf("Error: The Shelly device has missing configuration data (panel \"%s\")\n", p.EventTitle())
 		}
@@ -220,6 +228,7 @@ func (d DeviceTypeShelly) ScriptTo(p DeviceHardwareInterface, scriptName string,
 		GlowdashConsole.Write(T("ERROR: The last operation failed to complete"))
 		p.InvalidateInfo()
 		pr.ok = false
+		pr.err = ro.Err()
 		if DebugLevel >= 1 {
 			fmt.Printf("Error when executing http call on panel \"%s\"\n", p.EventTitle())
 		}
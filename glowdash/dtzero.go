@@ -7,6 +7,12 @@
 
 package main
 
+import "errors"
+
+var errDeviceTypeUnspecified = errors.New("the device type is not specified")
+var errEmptyDeviceIp = errors.New("the device has empty IP address")
+var errMissingDeviceConfig = errors.New("the device has missing configuration data")
+
 type DeviceManipulatorInterface interface {
 	SwitchTo(p DeviceHardwareInterface, toState bool, from string) SwitchSetResult
 	PerformThis(p DeviceHardwareInterface, fnc string, from string) PerformThisResult
@@ -16,16 +22,19 @@ type DeviceManipulatorInterface interface {
 	QueryScript(p DeviceHardwareInterface, scriptName string, from string) ScriptQueryResult
 }
 
+// The err holds the reason of the failure if ok is false (nil if the driver does not know it)
 type SwitchSetResult struct {
 	ok     bool
 	state  int
 	updIds []string
+	err    error
 }
 
 type PerformThisResult struct {
 	ok     bool
 	state  int
 	updIds []string
+	err    error
 }
 
 type SwitchQueryResult struct {
@@ -64,6 +73,7 @@ func (d DeviceTypeUnspecified) SwitchTo(p DeviceHardwareInterface, toState bool,
 		ok:     false,
 		state:  0,
 		updIds: []string{},
+		err:    errDeviceTypeUnspecified,
 	}
 }
 
@@ -73,6 +83,7 @@ func (d DeviceTypeUnspecified) PerformThis(p DeviceHardwareInterface, fnc string
 		ok:     false,
 		state:  0,
 		updIds: []string{},
+		err:    errDeviceTypeUnspecified,
 	}
 }
 
@@ -82,6 +93,7 @@ func (d DeviceTypeUnspecified) ScriptTo(p DeviceHardwareInterface, scriptName st
 		ok:     false,
 		state:  0,
 		updIds: []string{},
+		err:    errDeviceTypeUnspecified,
 	}
 }
 
@@ -28,17 +28,19 @@ import (
 type PanelTypes int
 
 const (
-	Group            PanelTypes = 0
-	Switch           PanelTypes = 1
-	Shading          PanelTypes = 2
-	Action           PanelTypes = 3
-	Script           PanelTypes = 4
-	Thermostat       PanelTypes = 5
-	ThermostatSwitch PanelTypes = 6
-	Sensors          PanelTypes = 7
-	Launch           PanelTypes = 8
-	ScheduleShortcut PanelTypes = 9
-	Unknown          PanelTypes = 99
+	Group              PanelTypes = 0
+	Switch             PanelTypes = 1
+	Shading            PanelTypes = 2
+	Action             PanelTypes = 3
+	Script             PanelTypes = 4
+	Thermostat         PanelTypes = 5
+	ThermostatSwitch   PanelTypes = 6
+	Sensors            PanelTypes = 7
+	Launch             PanelTypes = 8
+	ScheduleShortcut   PanelTypes = 9
+	ScheduleProfile    PanelTypes = 10
+	PresenceSimulation PanelTypes = 11
+	Unknown            PanelTypes = 99
 )
 
 type PanelBase struct {
@@ -79,7 +81,8 @@ type PanelInterface interface {
 	RequiredActionParameters(string) []string
 	HandleActionEvent(*ActionResponse, string, map[string]string)
 	DoAction(string, map[string]string) (string, []string, bool)
-	DoActionFromScheduler(string) []string
+	DoActionFromScheduler(string) ([]string, error)
+	SchedulableActions() []SchedulableAction
 	QueryDevice() []string
 	IsHwMatch(PanelTypes, string, int) bool
 	IsIpAddressMatch(string) bool
@@ -88,15 +91,47 @@ type PanelInterface interface {
 	InvalidateInfo()
 }
 
+// Implemented by the panels which operate a device by their buttons. It is the same as the DoAction, but the failed
+// device operation is returned as error. The DoAction reports "ok" in this case too, so the dashboard is refreshed.
+type PanelDeviceActionInterface interface {
+	DoDeviceAction(string, map[string]string) (string, []string, bool, error)
+}
+
+type ScheduleParamTypes int
+
+const (
+	ParamNone   ScheduleParamTypes = 0
+	ParamBool   ScheduleParamTypes = 1
+	ParamNumber ScheduleParamTypes = 2
+	ParamEnum   ScheduleParamTypes = 3
+)
+
+// An action of the panel which can be run by the scheduler. The action without parameter is stored by its name,
+// the others as name/value (e.g. tts/21.5), the value is on or off for the bool, a value of the range
+// for the number and one of the values for the enum parameter.
+type SchedulableAction struct {
+	Name   string
+	Title  string
+	Param  ScheduleParamTypes
+	Min    float64
+	Max    float64
+	Step   float64
+	Values []string
+}
+
 type PageTypes int
 
 const (
-	Settings     PageTypes = 0
-	ScheduleEdit PageTypes = 1
-	Console      PageTypes = 2
-	SensorStats  PageTypes = 3
-	SensorGraph  PageTypes = 4
-	UnknownPage  PageTypes = 99
+	Settings        PageTypes = 0
+	ScheduleEdit    PageTypes = 1
+	Console         PageTypes = 2
+	SensorStats     PageTypes = 3
+	SensorGraph     PageTypes = 4
+	RunningScripts  PageTypes = 5
+	ScriptTest      PageTypes = 6
+	ScriptTraces    PageTypes = 7
+	ScheduleHistory PageTypes = 8
+	UnknownPage     PageTypes = 99
 )
 
 type PageBase struct {
@@ -139,15 +174,17 @@ var UserFilesDirectory string = "userstuff"
 var LanguageFilesDirectory string = "lang"
 var StateConfigDirectory string = "."
 var WebServerPort string
-var WebUseSSE int = 0
+var WebUseSSE int = 2
 var WebSSEPort int = 8080
 var CommUseSSE int = 0
 var CommSSEHost string = ""
 var CommSSEPort int = 8085
 var BackgroudDevQueryNetDialerTimeout time.Duration = time.Duration(1200) * time.Millisecond
 var BackgroudDevQueryNetKeepaliveTimeout time.Duration = time.Duration(1200) * time.Millisecond
+var HttpRequestDefaultTimeout time.Duration = time.Duration(10000) * time.Millisecond
 var AssetVer string = "118"
 var MaxLogLines int = 128
+var SchedulerFireSecond int = 0
 
 var Panels []PanelInterface
 var Pages []PageInterface
@@ -177,25 +214,60 @@ func readConfig(yamlfile string) bool {
 	DebugLevel = configYAML.GetIntegerByPathWithDefault("/GlowDash/DebugLevel", 0)
 	LanguageCode = configYAML.GetStringByPathWithDefault("/GlowDash/LanguageCode", "")
 	MaxLogLines = int(configYAML.GetIntegerByPathWithDefault("/GlowDash/MaxLogLines", 128))
-	WebUseSSE = int(configYAML.GetIntegerByPathWithDefault("/GlowDash/WebUseSSE", 0))
+	WebUseSSE = int(configYAML.GetIntegerByPathWithDefault("/GlowDash/WebUseSSE", 2))
 	WebSSEPort = int(configYAML.GetIntegerByPathWithDefault("/GlowDash/WebSSEPort", 8080))
 	CommUseSSE = int(configYAML.GetIntegerByPathWithDefault("/GlowDash/CommUseSSE", 0))
 	CommSSEHost = configYAML.GetStringByPathWithDefault("/GlowDash/CommSSEHost", "127.0.0.1")
 	CommSSEPort = int(configYAML.GetIntegerByPathWithDefault("/GlowDash/CommSSEPort", 8085))
+	SSEHeartbeatInterval = int(configYAML.GetIntegerByPathWithDefault("/GlowDash/SSEHeartbeat", 25))
+	if SSEHeartbeatInterval < 1 {
+		SSEHeartbeatInterval = 25
+	}
+	SSEControlPort = int(configYAML.GetIntegerByPathWithDefault("/GlowDash/SSEControlPort", 0))
+	SSEControlHost = configYAML.GetStringByPathWithDefault("/GlowDash/SSEControlHost", "127.0.0.1")
+	ApiToken = configYAML.GetStringByPathWithDefault("/GlowDash/ApiToken", "")
 	WindInfoPollInterval = int64(configYAML.GetIntegerByPathWithDefault("/GlowDash/WindInfoPollInterval", 3600))
 	DashboardTitle = configYAML.GetStringByPathWithDefault("/GlowDash/DashboardTitle", "GlowDash")
 	StaticFilesDirectory = configYAML.GetStringByPathWithDefault("/GlowDash/StaticDirectory", "static")
 	UserFilesDirectory = configYAML.GetStringByPathWithDefault("/GlowDash/UserDirectory", "userstuff")
 	LanguageFilesDirectory = configYAML.GetStringByPathWithDefault("/GlowDash/LanguagesDirectory", "lang")
 	StateConfigDirectory = configYAML.GetStringByPathWithDefault("/GlowDash/StateConfigDirectory", ".")
+	LibraryDirectory = configYAML.GetStringByPathWithDefault("/GlowDash/LibraryDirectory", "")
 	ReadWindInfo, _ = configYAML.GetBoolByPath("/GlowDash/ReadWindInfo")
 	WeatherSource.Provider = configYAML.GetStringByPathWithDefault("/GlowDash/WeatherSource/Provider", "")
 	WeatherSource.ApiKey = configYAML.GetStringByPathWithDefault("/GlowDash/WeatherSource/ApiKey", "")
 	WeatherSource.Location = configYAML.GetStringByPathWithDefault("/GlowDash/WeatherSource/Location", "")
 	AssetVer = configYAML.GetStringByPathWithDefault("/GlowDash/AssetVer", AssetVer)
+	if configYAML.NodeExists("/GlowDash/Location/Latitude") && configYAML.NodeExists("/GlowDash/Location/Longitude") {
+		LocationLatitude = configYAML.GetNumberByPathWithDefault("/GlowDash/Location/Latitude", 0.0)
+		LocationLongitude = configYAML.GetNumberByPathWithDefault("/GlowDash/Location/Longitude", 0.0)
+		LocationSet = true
+		if LocationLatitude < -90.0 || LocationLatitude > 90.0 || LocationLongitude < -180.0 || LocationLongitude > 180.0 {
+			log.Printf("Error, wrong Location (Latitude: %f Longitude: %f), the sun calculations are disabled\n", LocationLatitude, LocationLongitude)
+			LocationSet = false
+		}
+	}
 
 	BackgroudDevQueryNetDialerTimeout = time.Duration(configYAML.GetIntegerByPathWithDefault("/GlowDash/BackDevDialerTimeout", 1200)) * time.Millisecond
 	BackgroudDevQueryNetKeepaliveTimeout = time.Duration(configYAML.GetIntegerByPathWithDefault("/GlowDash/BackDevKeepaliveTimeout", 1200)) * time.Millisecond
+	HttpRequestDefaultTimeout = time.Duration(configYAML.GetIntegerByPathWithDefault("/GlowDash/HttpRequestTimeout", 10000)) * time.Millisecond
+	ScriptMaxInstructions = int(configYAML.GetIntegerByPathWithDefault("/GlowDash/ScriptLimits/MaxInstructions", 1000000))
+	ScriptMaxWallTime = time.Duration(configYAML.GetIntegerByPathWithDefault("/GlowDash/ScriptLimits/MaxWallTimeSec", 600)) * time.Second
+	ScriptMaxNesting = int(configYAML.GetIntegerByPathWithDefault("/GlowDash/ScriptLimits/MaxNesting", 16))
+	MaxScriptTraces = int(configYAML.GetIntegerByPathWithDefault("/GlowDash/ScriptTrace/MaxTraces", 20))
+	MaxScriptTraceEntries = int(configYAML.GetIntegerByPathWithDefault("/GlowDash/ScriptTrace/MaxEntries", 5000))
+	ScheduleHistoryMaxEntries = int(configYAML.GetIntegerByPathWithDefault("/GlowDash/ScheduleHistoryMaxEntries", 1000))
+	if ScheduleHistoryMaxEntries < 1 {
+		ScheduleHistoryMaxEntries = 1
+	}
+	if err := SetTimeZone(configYAML.GetStringByPathWithDefault("/GlowDash/TimeZone", "")); err != nil {
+		log.Printf("Error, unknown TimeZone (%s), the local timezone is used\n", err)
+	}
+	SchedulerFireSecond = int(configYAML.GetIntegerByPathWithDefault("/GlowDash/SchedulerFireSecond", 0))
+	if SchedulerFireSecond < 0 || SchedulerFireSecond > 59 {
+		log.Printf("Error, wrong SchedulerFireSecond (%d), it must be 0-59\n", SchedulerFireSecond)
+		SchedulerFireSecond = 0
+	}
 
 	if !strings.HasSuffix(StaticFilesDirectory, "/") {
 		StaticFilesDirectory += "/"
@@ -224,7 +296,90 @@ func readConfig(yamlfile string) bool {
 					code = string(codeFileCode)
 				}
 			}
-			ProgramLibrary[name] = code
+			if err := setConfigLibraryProgram(name, code); err != nil {
+				log.Printf("Error in library program %s: %s\n", name, err.Error())
+			}
+			ProgramLibraryOnError[name] = configYAML.GetStringByPathWithDefault(fmt.Sprintf("/GlowDash/CommandLibrary/[%d]/OnError", i), "")
+			ProgramLibraryTrace[name] = configYAML.GetStringByPathWithDefault(fmt.Sprintf("/GlowDash/CommandLibrary/[%d]/Trace", i), "no") == "yes"
+		}
+	}
+
+	if LibraryDirectory != "" {
+		_, liberrors := loadLibraryDirectory()
+		for _, e := range liberrors {
+			log.Printf("Error, library: %s\n", e)
+		}
+	}
+
+	ReadAutomationsConfig(configYAML)
+
+	PersistentStateVariables = []string{}
+	if configYAML.NodeExists("/GlowDash/PersistentStateVariables") {
+		pdefs, _ := configYAML.GetArrayByPath("/GlowDash/PersistentStateVariables")
+		for i := 0; i < len(pdefs); i++ {
+			name := configYAML.GetStringByPathWithDefault(fmt.Sprintf("/GlowDash/PersistentStateVariables/[%d]", i), "")
+			if name != "" {
+				PersistentStateVariables = append(PersistentStateVariables, strings.TrimPrefix(name, "state."))
+			}
+		}
+	}
+
+	ScheduleProfiles = []string{}
+	if configYAML.NodeExists("/GlowDash/ScheduleProfiles") {
+		pdefs, _ := configYAML.GetArrayByPath("/GlowDash/ScheduleProfiles")
+		for i := 0; i < len(pdefs); i++ {
+			name := configYAML.GetStringByPathWithDefault(fmt.Sprintf("/GlowDash/ScheduleProfiles/[%d]", i), "")
+			if name != "" && !strings.ContainsAny(name, " ,;:") {
+				ScheduleProfiles = append(ScheduleProfiles, name)
+			} else {
+				log.Printf("Error, wrong schedule profile name: \"%s\"\n", name)
+			}
+		}
+	}
+	ScheduleCalendarFile = configYAML.GetStringByPathWithDefault("/GlowDash/ScheduleCalendar/File", "")
+	ScheduleCalendarICals = []ScheduleCalendarSource{}
+	if configYAML.NodeExists("/GlowDash/ScheduleCalendar/ICal") {
+		idefs, _ := configYAML.GetArrayByPath("/GlowDash/ScheduleCalendar/ICal")
+		for i := 0; i < len(idefs); i++ {
+			source := configYAML.GetStringByPathWithDefault(fmt.Sprintf("/GlowDash/ScheduleCalendar/ICal/[%d]/Source", i), "")
+			profile := configYAML.GetStringByPathWithDefault(fmt.Sprintf("/GlowDash/ScheduleCalendar/ICal/[%d]/Profile", i), "")
+			if source == "" || !isScheduleProfile(profile) {
+				log.Printf("Error, wrong ScheduleCalendar ICal source or unknown profile: %s\n", profile)
+				continue
+			}
+			ScheduleCalendarICals = append(ScheduleCalendarICals, ScheduleCalendarSource{source: source, profile: profile})
+		}
+	}
+
+	PresencePanels = []string{}
+	if configYAML.NodeExists("/GlowDash/PresenceSimulation/Panels") {
+		pdefs, _ := configYAML.GetArrayByPath("/GlowDash/PresenceSimulation/Panels")
+		for i := 0; i < len(pdefs); i++ {
+			id := configYAML.GetStringByPathWithDefault(fmt.Sprintf("/GlowDash/PresenceSimulation/Panels/[%d]", i), "")
+			if id != "" {
+				PresencePanels = append(PresencePanels, id)
+			}
+		}
+	}
+	PresenceHistoryWeeks = configYAML.GetIntegerByPathWithDefault("/GlowDash/PresenceSimulation/HistoryWeeks", 2)
+	if PresenceHistoryWeeks < 1 {
+		PresenceHistoryWeeks = 1
+	}
+	PresenceRandomMinutes = configYAML.GetIntegerByPathWithDefault("/GlowDash/PresenceSimulation/RandomMinutes", 15)
+	if PresenceRandomMinutes < 0 {
+		PresenceRandomMinutes = 0
+	}
+
+	DryRunMocks = []DryRunMock{}
+	if configYAML.NodeExists("/GlowDash/DryRunMocks") {
+		mdefs, _ := configYAML.GetArrayByPath("/GlowDash/DryRunMocks")
+		for i := 0; i < len(mdefs); i++ {
+			mock, ok := ParseDryRunMock(configYAML.GetStringByPathWithDefault(fmt.Sprintf("/GlowDash/DryRunMocks/[%d]", i), ""))
+			if ok {
+				DryRunMocks = append(DryRunMocks, mock)
+			} else {
+				log.Printf("Error, wrong dry run mock definition at index %d\n", i)
+			}
 		}
 	}
 
@@ -268,6 +423,12 @@ func readConfig(yamlfile string) bool {
 		if typ == "ScheduleShortcut" {
 			p = NewPanelScheduleShortcut()
 		}
+		if typ == "ScheduleProfile" {
+			p = NewPanelScheduleProfile()
+		}
+		if typ == "PresenceSimulation" {
+			p = NewPanelPresenceSimulation()
+		}
 
 		if p != nil {
 			p.LoadBaseConfig(configYAML, i)
@@ -296,6 +457,18 @@ func readConfig(yamlfile string) bool {
 		if typ == "SensorGraph" {
 			p = NewPageSensorGraph()
 		}
+		if typ == "RunningScripts" {
+			p = NewPageRunningScripts()
+		}
+		if typ == "ScriptTest" {
+			p = NewPageScriptTest()
+		}
+		if typ == "ScriptTraces" {
+			p = NewPageScriptTraces()
+		}
+		if typ == "ScheduleHistory" {
+			p = NewPageScheduleHistory()
+		}
 
 		if p != nil {
 			p.LoadBaseConfig(configYAML, i)
@@ -473,6 +646,7 @@ func handleHit(w http.ResponseWriter, r *http.Request) {
 	affrectedIds := []string{}
 	rap := strings.Split(r.RemoteAddr, ":")
 	if len(rap) == 2 && len(rap[0]) > 0 {
+		AutomationHit(rap[0])
 		for i := 0; i < len(Panels); i++ {
 			if Panels[i].IsIpAddressMatch(rap[0]) {
 				affrectedIds = append(affrectedIds, Panels[i].IdStr())
@@ -493,6 +667,14 @@ func (router *httpRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		handleHit(w, r)
 		return
 	}
+	if strings.HasPrefix(r.URL.Path, "/api/v1/") {
+		handleApi(w, r)
+		return
+	}
+	if r.URL.Path == "/sse" {
+		handleSSE(w, r)
+		return
+	}
 	if strings.HasPrefix(r.URL.Path, "/static/") {
 		getStatic(w, r, "static")
 		return
@@ -513,6 +695,10 @@ func (router *httpRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		getCustomPage(w, r, r.URL.Path[6:])
 		return
 	}
+	if r.URL.Path == "/schedules/export" {
+		handleSchedulesExport(w, r)
+		return
+	}
 	if r.URL.Path == "/" {
 		getPage(w, r, "")
 		return
@@ -529,34 +715,49 @@ func gracefulShutdown() {
 		fmt.Printf("Saving schedules\n")
 	}
 	SaveSchedulesIfRequired()
+	SaveStateVariablesIfRequired()
+	SaveScheduleHistoryIfRequired()
 	os.Exit(0)
 }
 
+// Reloads the LibraryDirectory on SIGHUP
+func libraryReloader() {
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	for range reload {
+		if DebugLevel > 0 {
+			fmt.Printf("Reloading library\n")
+		}
+		ReloadLibrary()
+	}
+}
+
+// Runs the minute tasks at the start of every minute (or at the configured second).
+// The timer is computed to the next run, so the schedules are fired in time.
 func schedulerRunner() {
-	ticker := time.NewTicker(time.Second * 20)
-	defer ticker.Stop()
-	done := make(chan bool)
-	last_hour := -1
-	last_min := -1
+	last_run := int64(-1)
 	for {
-		select {
-		case <-done:
-			return
-		case t := <-ticker.C:
-			if last_min != t.Minute() || last_hour != t.Hour() {
-				last_hour = t.Hour()
-				last_min = t.Minute()
-				CheckSchedules()
-			}
+		timer := time.NewTimer(time.Until(nextSchedulerRun(Now())))
+		<-timer.C
+		t := Now()
+		minute := t.Unix() / 60
+		if minute == last_run {
+			continue
 		}
+		last_run = minute
+		CheckSchedules()
+		CheckPresenceSimulation(t)
+		SaveStateVariablesIfRequired()
+		SaveScheduleHistoryIfRequired()
+		AutomationTimeTick(t)
 	}
 }
 
 func runGlowdashStart() {
-	code, ok := ProgramLibrary["GlowdashStart"]
+	code, ok := getLibraryProgram("GlowdashStart")
 	if ok {
 		relatedPanels := []string{}
-		ExecuteCommands(code, map[string]string{}, &relatedPanels)
+		ExecuteCommandsWithOptions(code, map[string]string{}, &relatedPanels, LibraryProgramRunOptions("GlowdashStart"))
 		if DebugLevel > 3 {
 			fmt.Printf("GlowdashStart program executed\n")
 		}
@@ -570,16 +771,27 @@ func main() {
 		return
 	}
 
+	if os.Args[1] == "dryrun" {
+		os.Exit(runDryRunCommand(os.Args[2:]))
+	}
+
 	configFileName = os.Args[1]
 	StaticFilesDirectory = ""
 	readConfig(configFileName)
 	GlowdashConsole.Init()
 	GlowdashConsole.Write(T("Glowdash started, version: {{version}}", map[string]any{"version": GlowdashVersion}))
 
-	LastWindInfo.RequestTime = time.Date(2000, 1, 1, 8, 00, 00, 100, time.Local)
+	LastWindInfo.RequestTime = time.Date(2000, 1, 1, 8, 00, 00, 100, TimeLocation)
 	mime.AddExtensionType(".css", "text/css")
 
-	ReadSchedulesFromFileDb()
+	ReadSchedulesFromFile()
+	ReadStateVariablesFromFile()
+	ReadScheduleProfileFromFile()
+	ReadPresenceHistoryFromFile()
+	ReadScheduleHistoryFromFile()
+	ReadScheduleEndsFromFile()
+	ReadScheduleRetriesFromFile()
+	ReloadScheduleCalendarIfRequired(Now())
 
 	var myrouter httpRouter
 	if DebugLevel > 0 {
@@ -588,7 +800,11 @@ func main() {
 
 	runGlowdashStart()
 	go gracefulShutdown()
+	go libraryReloader()
 	go schedulerRunner()
+	if BuiltinSSEEnabled() && SSEControlPort > 0 {
+		go sseControlListener()
+	}
 	err := http.ListenAndServe(":"+WebServerPort, &myrouter)
 	if errors.Is(err, http.ErrServerClosed) {
 		fmt.Printf("Server closed\n")
@@ -3,8 +3,11 @@ module glowdash
 go 1.22.5
 
 require (
-	github.com/hyper-prog/smartjson v1.0.13 // indirect
+	github.com/hyper-prog/smartjson v1.0.13
+	github.com/hyper-prog/smartyaml v1.0.13
+)
+
+require (
 	github.com/hyper-prog/smartjsonyamlstub v1.0.13 // indirect
-	github.com/hyper-prog/smartyaml v1.0.13 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
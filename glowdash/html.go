@@ -11,11 +11,10 @@ import (
 	"fmt"
 	"math/rand"
 	"net/http"
-	"time"
 )
 
 func htmlStart() string {
-	t := time.Now()
+	t := Now()
 	return `<!DOCTYPE html>
 	<meta charset="UTF-8" />
 	<meta name="viewport" content="width=device-width, initial-scale=1.0, maximum-scale=1.0, user-scalable=0" />
@@ -44,7 +43,7 @@ func htmlEnd() string {
 
 func htmlHeaderLine(sub string) string {
 	html := "<div class=\"header\">"
-	now := time.Now()
+	now := Now()
 	if ReadWindInfo {
 		if int64(now.Sub(LastWindInfo.RequestTime)) > (1000000000 * WindInfoPollInterval) {
 			if DebugLevel > 0 {
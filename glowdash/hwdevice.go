@@ -187,6 +187,7 @@ func (p PanelHwDevBased) IsIpAddressMatch(deviceIp string) bool {
 
 func (p *PanelHwDevBased) RefreshHwStateIfMatch(fromPanelType PanelTypes, fromDeviceIp string, fromInDeviceId int, fromScriptName string, State int, InputState int) string {
 	if p.panelType == fromPanelType && p.deviceIp == fromDeviceIp && p.inDeviceId == fromInDeviceId {
+		AutomationPanelStateUpdate(p.idStr, p.hasValidInfo, p.state, State, p.inputState, InputState)
 		p.state = State
 		p.inputState = InputState
 		p.hasValidInfo = true
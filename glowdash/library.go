@@ -0,0 +1,252 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"fmt"
+	"html"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The *.gds files of this directory (and its subdirectories) are loaded as library programs
+var LibraryDirectory string = ""
+
+const maxIncludeDepth int = 8
+
+var programLibraryMutex sync.RWMutex
+
+// Names of the programs loaded from the LibraryDirectory, these are replaced on reload
+var libraryFilePrograms map[string]bool = map[string]bool{}
+
+// The code of the CommandLibrary programs before the include, the includes are expanded again on reload
+var configLibrarySources map[string]string = map[string]string{}
+
+func getLibraryProgram(name string) (string, bool) {
+	programLibraryMutex.RLock()
+	defer programLibraryMutex.RUnlock()
+	code, ok := ProgramLibrary[name]
+	return code, ok
+}
+
+func setLibraryProgram(name string, code string) {
+	programLibraryMutex.Lock()
+	defer programLibraryMutex.Unlock()
+	ProgramLibrary[name] = code
+}
+
+func libraryProgramNames() []string {
+	programLibraryMutex.RLock()
+	defer programLibraryMutex.RUnlock()
+	names := []string{}
+	for name := range ProgramLibrary {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Finds the library program called from the running program. A name without namespace is searched
+// in the namespace of the caller first (Run openAll in shading.closeAll means shading.openAll).
+func resolveLibraryProgram(ctx *RunContext, name string) (string, string, bool) {
+	if i := strings.LastIndex(ctx.options.Name, "."); i > 0 && !strings.Contains(name, ".") {
+		fullname := ctx.options.Name[:i] + "." + name
+		if code, ok := getLibraryProgram(fullname); ok {
+			return fullname, code, true
+		}
+	}
+	code, ok := getLibraryProgram(name)
+	return name, code, ok
+}
+
+func includeFilePath(name string) string {
+	if filepath.IsAbs(name) || LibraryDirectory == "" {
+		return name
+	}
+	return filepath.Join(LibraryDirectory, name)
+}
+
+// Replaces the "Include <file>" lines with the content of the file (recursively).
+// The relative file names are relative to the LibraryDirectory.
+func expandIncludes(code string) (string, error) {
+	return expandIncludesRec(code, 0, []string{})
+}
+
+func expandIncludesRec(code string, depth int, stack []string) (string, error) {
+	if !strings.Contains(code, "Include ") {
+		return code, nil
+	}
+	lines := strings.Split(code, "\n")
+	for i, line := range lines {
+		l := strings.TrimSpace(line)
+		if !strings.HasPrefix(l, "Include ") {
+			continue
+		}
+		if depth >= maxIncludeDepth {
+			return code, fmt.Errorf("include depth limit (%d) exceeded at %s", maxIncludeDepth, l)
+		}
+		path := includeFilePath(strings.TrimSpace(l[8:]))
+		abspath, _ := filepath.Abs(path)
+		for _, s := range stack {
+			if s == abspath {
+				return code, fmt.Errorf("recursive include of %s", path)
+			}
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return code, fmt.Errorf("cannot include file: %s", err)
+		}
+		expanded, err := expandIncludesRec(strings.TrimRight(string(content), "\n"), depth+1, append(stack, abspath))
+		if err != nil {
+			return code, err
+		}
+		lines[i] = expanded
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// Sets a CommandLibrary program of the config, its includes are expanded now and on every library reload
+func setConfigLibraryProgram(name string, code string) error {
+	programLibraryMutex.Lock()
+	configLibrarySources[name] = code
+	programLibraryMutex.Unlock()
+	expanded, err := expandIncludes(code)
+	setLibraryProgram(name, expanded)
+	return err
+}
+
+// Expands the includes of the CommandLibrary programs and the Action panel commands again,
+// so the changed included files are used without restart. Returns the errors.
+func reloadIncludes() []string {
+	errors := []string{}
+	programLibraryMutex.RLock()
+	sources := map[string]string{}
+	for name, code := range configLibrarySources {
+		sources[name] = code
+	}
+	programLibraryMutex.RUnlock()
+	for name, code := range sources {
+		expanded, err := expandIncludes(code)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %s", name, err))
+			continue
+		}
+		setLibraryProgram(name, expanded)
+	}
+	for i := 0; i < len(Panels); i++ {
+		if action, isAction := Panels[i].(*PanelAction); isAction {
+			if err := action.reloadIncludes(); err != nil {
+				errors = append(errors, fmt.Sprintf("%s: %s", action.IdStr(), err))
+			}
+		}
+	}
+	return errors
+}
+
+// Splits the content of a library file to programs. The "Program <name>" ... "EndProgram" blocks are
+// separate programs named <namespace>.<name>, a file without Program blocks is one program named <namespace>.
+func parseLibraryFile(namespace string, content string) map[string]string {
+	programs := map[string]string{}
+	current := ""
+	inProgram := false
+	code := []string{}
+	for _, line := range strings.Split(content, "\n") {
+		l := strings.TrimSpace(line)
+		if strings.HasPrefix(l, "Program ") && !inProgram {
+			current = namespace + "." + strings.TrimSpace(l[8:])
+			inProgram = true
+			code = []string{}
+			continue
+		}
+		if l == "EndProgram" && inProgram {
+			programs[current] = strings.Join(code, "\n")
+			inProgram = false
+			continue
+		}
+		if inProgram {
+			code = append(code, line)
+		}
+	}
+	if inProgram {
+		log.Printf("Error, missing EndProgram of %s\n", current)
+		programs[current] = strings.Join(code, "\n")
+	}
+	if len(programs) == 0 {
+		programs[namespace] = content
+	}
+	return programs
+}
+
+// Loads the programs of the LibraryDirectory, the previously loaded file programs are removed.
+// Returns the number of loaded programs and the errors.
+func loadLibraryDirectory() (int, []string) {
+	errors := []string{}
+	loaded := map[string]string{}
+	if LibraryDirectory != "" {
+		err := filepath.WalkDir(LibraryDirectory, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				errors = append(errors, err.Error())
+				return nil
+			}
+			if d.IsDir() || !strings.HasSuffix(d.Name(), ".gds") {
+				return nil
+			}
+			rel, _ := filepath.Rel(LibraryDirectory, path)
+			namespace := strings.ReplaceAll(filepath.ToSlash(strings.TrimSuffix(rel, ".gds")), "/", ".")
+			content, rerr := os.ReadFile(path)
+			if rerr != nil {
+				errors = append(errors, rerr.Error())
+				return nil
+			}
+			for name, code := range parseLibraryFile(namespace, string(content)) {
+				expanded, ierr := expandIncludes(code)
+				if ierr != nil {
+					errors = append(errors, fmt.Sprintf("%s: %s", name, ierr))
+					continue
+				}
+				loaded[name] = expanded
+			}
+			return nil
+		})
+		if err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
+
+	programLibraryMutex.Lock()
+	defer programLibraryMutex.Unlock()
+	for name := range libraryFilePrograms {
+		delete(ProgramLibrary, name)
+	}
+	libraryFilePrograms = map[string]bool{}
+	for name, code := range loaded {
+		if _, exists := ProgramLibrary[name]; exists {
+			errors = append(errors, fmt.Sprintf("%s: already defined in the CommandLibrary", name))
+			continue
+		}
+		ProgramLibrary[name] = code
+		libraryFilePrograms[name] = true
+	}
+	return len(libraryFilePrograms), errors
+}
+
+// Reloads the LibraryDirectory and the included files without restart, the result is written to the console
+func ReloadLibrary() {
+	count, errors := loadLibraryDirectory()
+	errors = append(errors, reloadIncludes()...)
+	for _, e := range errors {
+		log.Printf("Error, library: %s\n", e)
+		GlowdashConsole.Write(T("ERROR: Library: {{message}}", map[string]any{"message": html.EscapeString(e)}))
+	}
+	GlowdashConsole.Write(T("Library reloaded, {{count}} programs loaded from files", map[string]any{"count": count}))
+}
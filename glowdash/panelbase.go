@@ -70,8 +70,12 @@ func (p PanelBase) DoAction(actionName string) (string, []string, bool) {
 	return "", []string{}, false
 }
 
-func (p PanelBase) DoActionFromScheduler(actionName string) []string {
-	return []string{}
+func (p PanelBase) DoActionFromScheduler(actionName string) ([]string, error) {
+	return []string{}, errUnknownScheduledAction
+}
+
+func (p PanelBase) SchedulableActions() []SchedulableAction {
+	return []SchedulableAction{}
 }
 
 func (p *PanelBase) LoadBaseConfig(sy smartyaml.SmartYAML, indexInConfig int) {
@@ -0,0 +1,287 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The presence simulation replays the switching history of the selected panels from the previous weeks
+// with random time offsets, so the home looks lived-in during a vacation.
+var PresencePanels []string = []string{}
+var PresenceHistoryWeeks int = 2
+var PresenceRandomMinutes int = 15
+
+type PresenceEvent struct {
+	time    time.Time
+	panelId string
+	action  string
+}
+
+var presenceHistory []PresenceEvent = []PresenceEvent{}
+var presenceActive bool = false
+var presenceMutex sync.Mutex
+
+// The replay plan of the current day
+var presencePlan []PresenceEvent = []PresenceEvent{}
+var presencePlanDay string = ""
+var presenceLastCheck time.Time
+
+func isPresencePanel(panelId string) bool {
+	for _, id := range PresencePanels {
+		if id == panelId {
+			return true
+		}
+	}
+	return false
+}
+
+func PresenceSimulationActive() bool {
+	presenceMutex.Lock()
+	defer presenceMutex.Unlock()
+	return presenceActive
+}
+
+// Switches the simulation on or off, the state is saved and kept across restarts
+func SetPresenceSimulation(active bool) {
+	presenceMutex.Lock()
+	changed := presenceActive != active
+	presenceActive = active
+	presencePlanDay = ""
+	presenceLastCheck = Now()
+	presenceMutex.Unlock()
+	if !changed {
+		return
+	}
+	if active {
+		GlowdashConsole.Write(T("Presence simulation started"))
+	} else {
+		GlowdashConsole.Write(T("Presence simulation stopped"))
+	}
+	SavePresenceStateToFile()
+	ids := presencePanelIds()
+	if len(ids) > 0 {
+		panelUpdateRequestSSE(ids)
+	}
+}
+
+// The replayed action of the state change: on/off of the switches, open/close of the shadings
+func presenceActionOfStateChange(panelId string, oldState int, newState int) string {
+	panel := GetPanelById(panelId)
+	if panel == nil {
+		return ""
+	}
+	if panel.PanelType() == Switch {
+		if newState == 1 {
+			return "on"
+		}
+		return "off"
+	}
+	if panel.PanelType() == Shading {
+		if newState > oldState {
+			return "open"
+		}
+		return "close"
+	}
+	return ""
+}
+
+// Records the state change of a selected panel. The simulated switching is not recorded.
+func PresenceRecordStateChange(panelId string, oldState int, newState int) {
+	if oldState == newState || !isPresencePanel(panelId) || PresenceSimulationActive() {
+		return
+	}
+	action := presenceActionOfStateChange(panelId, oldState, newState)
+	if action == "" {
+		return
+	}
+	now := Now()
+	presenceMutex.Lock()
+	// The moving shading reports more positions, only the first one is recorded
+	for i := len(presenceHistory) - 1; i >= 0 && now.Sub(presenceHistory[i].time) < 2*time.Minute; i-- {
+		if presenceHistory[i].panelId == panelId && presenceHistory[i].action == action {
+			presenceMutex.Unlock()
+			return
+		}
+	}
+	e := PresenceEvent{time: now, panelId: panelId, action: action}
+	limit := now.AddDate(0, 0, -7*PresenceHistoryWeeks-1)
+	for len(presenceHistory) > 0 && presenceHistory[0].time.Before(limit) {
+		presenceHistory = presenceHistory[1:]
+	}
+	presenceHistory = append(presenceHistory, e)
+	presenceMutex.Unlock()
+
+	f, err := os.OpenFile(StateConfigDirectory+"/presencehistory.txt", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Printf("Cannot write presencehistory.txt: %s\n", err)
+		return
+	}
+	defer f.Close()
+	f.WriteString(presenceEventLine(e))
+}
+
+func presenceEventLine(e PresenceEvent) string {
+	return e.time.Format(time.RFC3339) + " " + e.panelId + " " + e.action + "\n"
+}
+
+// Reads the history file and drops the events older than the used weeks
+func ReadPresenceHistoryFromFile() {
+	if len(PresencePanels) == 0 {
+		return
+	}
+	content, err := os.ReadFile(StateConfigDirectory + "/presencehistory.txt")
+	if err == nil {
+		limit := Now().AddDate(0, 0, -7*PresenceHistoryWeeks-1)
+		history := []PresenceEvent{}
+		dropped := false
+		for _, line := range strings.Split(string(content), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 3 {
+				continue
+			}
+			t, terr := time.Parse(time.RFC3339, fields[0])
+			if terr != nil || t.Before(limit) {
+				dropped = true
+				continue
+			}
+			history = append(history, PresenceEvent{time: t.In(TimeLocation), panelId: fields[1], action: fields[2]})
+		}
+		presenceMutex.Lock()
+		presenceHistory = history
+		presenceMutex.Unlock()
+		if dropped {
+			SavePresenceHistoryToFile()
+		}
+	}
+
+	state, err := os.ReadFile(StateConfigDirectory + "/presencesimulation.txt")
+	if err == nil && strings.TrimSpace(string(state)) == "on" {
+		presenceMutex.Lock()
+		presenceActive = true
+		presenceLastCheck = Now()
+		presenceMutex.Unlock()
+	}
+}
+
+func SavePresenceHistoryToFile() {
+	presenceMutex.Lock()
+	content := ""
+	for _, e := range presenceHistory {
+		content += presenceEventLine(e)
+	}
+	presenceMutex.Unlock()
+	err := writeFileAtomic(StateConfigDirectory+"/presencehistory.txt", []byte(content))
+	if err != nil {
+		fmt.Printf("Cannot write presencehistory.txt: %s\n", err)
+	}
+}
+
+func SavePresenceStateToFile() {
+	state := "off"
+	if PresenceSimulationActive() {
+		state = "on"
+	}
+	err := writeFileAtomic(StateConfigDirectory+"/presencesimulation.txt", []byte(state+"\n"))
+	if err != nil {
+		fmt.Printf("Cannot write presencesimulation.txt: %s\n", err)
+	}
+}
+
+// Builds the plan of the day: the events of the same weekday of a random previous week
+// with a random offset for every event. Falls back to the other weeks if the chosen one is empty.
+func buildPresencePlan(day time.Time) []PresenceEvent {
+	plan := []PresenceEvent{}
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	first := rand.Intn(PresenceHistoryWeeks)
+	for w := 0; w < PresenceHistoryWeeks && len(plan) == 0; w++ {
+		week := (first+w)%PresenceHistoryWeeks + 1
+		from := start.AddDate(0, 0, -7*week)
+		to := from.AddDate(0, 0, 1)
+		for _, e := range presenceHistory {
+			if e.time.Before(from) || !e.time.Before(to) {
+				continue
+			}
+			offset := time.Duration(rand.Intn(2*PresenceRandomMinutes+1)-PresenceRandomMinutes) * time.Minute
+			plan = append(plan, PresenceEvent{time: e.time.AddDate(0, 0, 7*week).Add(offset), panelId: e.panelId, action: e.action})
+		}
+	}
+	sort.Slice(plan, func(i, j int) bool { return plan[i].time.Before(plan[j].time) })
+	return plan
+}
+
+// Called by the scheduler in every minute, fires the planned events since the last check
+func CheckPresenceSimulation(now time.Time) {
+	if len(PresencePanels) == 0 {
+		return
+	}
+	presenceMutex.Lock()
+	if !presenceActive {
+		presenceMutex.Unlock()
+		return
+	}
+	day := now.Format("2006-01-02")
+	if presencePlanDay != day {
+		presencePlanDay = day
+		presencePlan = buildPresencePlan(now)
+		if DebugLevel > 0 {
+			fmt.Printf("Presence simulation plan of %s: %d events\n", day, len(presencePlan))
+		}
+	}
+	fire := []PresenceEvent{}
+	for _, e := range presencePlan {
+		if e.time.After(presenceLastCheck) && !e.time.After(now) {
+			fire = append(fire, e)
+		}
+	}
+	presenceLastCheck = now
+	presenceMutex.Unlock()
+
+	for _, e := range fire {
+		panel := GetPanelById(e.panelId)
+		if panel == nil {
+			continue
+		}
+		if DebugLevel > 0 {
+			fmt.Printf("Presence simulation: Panel(%s) - %s\n", e.panelId, e.action)
+		}
+		refreshIds, _ := panel.DoActionFromScheduler(e.action)
+		if len(refreshIds) > 0 {
+			panelUpdateRequestSSE(refreshIds)
+		}
+	}
+}
+
+// Returns the number of the planned and the remaining events of the day
+func PresencePlanInfo() (int, int) {
+	presenceMutex.Lock()
+	defer presenceMutex.Unlock()
+	remaining := 0
+	for _, e := range presencePlan {
+		if e.time.After(presenceLastCheck) {
+			remaining++
+		}
+	}
+	return len(presencePlan), remaining
+}
+
+func presencePanelIds() []string {
+	ids := []string{}
+	for i := 0; i < len(Panels); i++ {
+		if Panels[i].PanelType() == PresenceSimulation {
+			ids = append(ids, Panels[i].IdStr())
+		}
+	}
+	return ids
+}
@@ -0,0 +1,178 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+
+	"github.com/hyper-prog/smartyaml"
+)
+
+type PanelPresenceSimulation struct {
+	PanelBase
+}
+
+func NewPanelPresenceSimulation() *PanelPresenceSimulation {
+	return &PanelPresenceSimulation{
+		PanelBase{
+			idStr:        "",
+			panelType:    PresenceSimulation,
+			title:        "",
+			eventtitle:   "",
+			subPage:      "",
+			thumbImg:     "",
+			deviceType:   "",
+			hide:         false,
+			hasPowerInfo: false,
+			index:        0,
+		},
+	}
+}
+
+func (p *PanelPresenceSimulation) LoadCustomConfig(sy smartyaml.SmartYAML, indexInConfig int) {
+}
+
+func (p PanelPresenceSimulation) PanelHtml(withContainer bool) string {
+	templ, _ := template.New("PcT").Parse(`
+	<div class="badge badge-left" style="max-width: 100%;">
+		<div class="label label-s no-radius-bottom-left-diagonal">
+			<span class="mr-xs icon-grid icon-grid-xs"><i class="fas fa-sched3"></i></span>
+			<div class="label-value-container">
+				<p class="text-600 miniature-styles text-nowrap">{{.PTypText}}</p>
+			</div>
+		</div>
+	</div>
+
+	<div class="main-container {{if .NoPanels}}panelnoinfo{{end}}" data-refid="b-{{.Id}}">
+		<div class="main-container-top">
+			<div class="placeholdersp"></div>
+			<div class="title-container mt-s">
+				<p class="title text-bold body-small-styles">{{.Title}}</p>
+			</div>
+			<div class="ctrlline-container mt-s">
+				<p class="text-600 title text-bold body-small-styles">{{.StateText}}</p>
+			</div>
+			{{if .Active}}
+			<div class="ctrlline-container">
+				<p class="text-600 miniature-styles">{{.PlanText}}</p>
+			</div>
+			{{end}}
+		</div>
+		<div class="bottom-slot-container d-flex justify-content-center">
+			<button id="b-{{.Id}}-toggle" class="align-self-center device-button primary medium jsaction {{if not .Active}}inactive{{end}} {{if .NoPanels}}inactive noinfo{{end}}">
+				<span class="device-action-border">
+					<span class="device-action">
+						<span class="text-primary icon-grid icon-grid-s">
+							<i class="fa fa-sched1"></i>
+						</span>
+					</span>
+				</span>
+			</button>
+		</div>
+	</div>`)
+
+	active := PresenceSimulationActive()
+	planned, remaining := PresencePlanInfo()
+	stateText := T("OFF")
+	if active {
+		stateText = T("ON")
+	}
+
+	pass := struct {
+		Title     string
+		Id        string
+		PTypText  string
+		StateText string
+		PlanText  string
+		Active    bool
+		NoPanels  bool
+	}{
+		Title:     p.title,
+		Id:        p.idStr,
+		PTypText:  T("Presence simulation"),
+		StateText: stateText,
+		PlanText:  T("{{remaining}} of {{planned}} switchings left today", map[string]any{"remaining": remaining, "planned": planned}),
+		Active:    active,
+		NoPanels:  len(PresencePanels) == 0,
+	}
+
+	buffer := bytes.Buffer{}
+	templ.Execute(&buffer, pass)
+
+	if withContainer {
+		return fmt.Sprintf("<div id=\"pc-%s\" class=\"widget-card\" tabindex=\"-1\">", p.IdStr()) +
+			buffer.String() + "</div>"
+	}
+
+	return buffer.String()
+}
+
+func (p *PanelPresenceSimulation) SetHwDeviceId(id int) {
+
+}
+
+func (p *PanelPresenceSimulation) RefreshHwStateIfMatch(fromPanelType PanelTypes, fromDeviceIp string, fromInDeviceId int, fromScriptName string, State int, InputState int) string {
+	return p.idStr
+}
+
+func (p PanelPresenceSimulation) IsActionIdMatch(aId string) bool {
+	if "b-"+p.idStr+"-toggle" == aId {
+		return true
+	}
+	if "b-"+p.idStr+"-update" == aId {
+		return true
+	}
+	return false
+}
+
+func (p PanelPresenceSimulation) DoAction(actionName string, parameters map[string]string) (string, []string, bool) {
+	var stateChanged bool = false
+	var updatedIds []string = []string{}
+	if actionName == "toggle" && len(PresencePanels) > 0 {
+		SetPresenceSimulation(!PresenceSimulationActive())
+		CheckPresenceSimulation(Now())
+		stateChanged = true
+		updatedIds = append(updatedIds, presencePanelIds()...)
+	}
+	if actionName == "update" {
+		updatedIds = append(updatedIds, p.QueryDevice()...)
+	}
+	return "ok", updatedIds, stateChanged
+}
+
+func (p PanelPresenceSimulation) DoActionFromScheduler(actionName string) ([]string, error) {
+	if actionName == "on" || actionName == "off" {
+		SetPresenceSimulation(actionName == "on")
+		return presencePanelIds(), nil
+	}
+	return []string{}, errUnknownScheduledAction
+}
+
+func (p PanelPresenceSimulation) SchedulableActions() []SchedulableAction {
+	return []SchedulableAction{
+		{Name: "on", Title: "Switch On"},
+		{Name: "off", Title: "Switch Off"},
+	}
+}
+
+func (p *PanelPresenceSimulation) QueryDevice() []string {
+	return []string{p.idStr}
+}
+
+func (p PanelPresenceSimulation) ExposeVariables() map[string]string {
+	var m map[string]string = map[string]string{}
+
+	m["Panel.Id"] = p.idStr
+	m["Panel.Title"] = p.title
+	m["Panel.SubPage"] = p.subPage
+	m["Panel.Index"] = fmt.Sprintf("%d", p.index)
+	m["Panel.State"] = TrueFalseTextFromBool(PresenceSimulationActive())
+	return m
+}
@@ -9,9 +9,13 @@ package main
 
 import (
 	"fmt"
+	"html"
+	"math"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/hyper-prog/smartjson"
 )
 
 type RunContext struct {
@@ -19,21 +23,98 @@ type RunContext struct {
 	jqrvariables map[string]JsonHttpQuery
 	iwblocks     IntStack
 	whileblocks  IntStack
+	httpreq      HttpRequestSpec
+	options      ProgramRunOptions
+	tryblocks    []TryBlock
+	ip           int
+	errorRaised  bool
+	errorMessage string
+	errorIp      int
+	retryIp      int
+	retryCount   int
+	lastError    string
+	lastErrorIp  int
+	aborted      bool
+}
+
+type ProgramRunOptions struct {
+	Name    string
+	OnError string
+	Trace   bool
+	run     *RunningScript
+	depth   int
+	dryRun  *DryRun
+	trace   *ScriptTrace
 }
 
-var GlowdashStateVariables = map[string]string{}
+type TryBlock struct {
+	ip         int
+	iwdepth    int
+	whiledepth int
+	skipped    bool
+	inCatch    bool
+}
+
+var ProgramLibraryOnError = map[string]string{}
 
 func ExecuteCommands(program string, contextVariables map[string]string, relatedPanels *[]string) map[string]string {
+	return ExecuteCommandsWithOptions(program, contextVariables, relatedPanels, ProgramRunOptions{Name: "", OnError: ""})
+}
+
+func LibraryProgramRunOptions(name string) ProgramRunOptions {
+	return ProgramRunOptions{Name: name, OnError: ProgramLibraryOnError[name], Trace: ProgramLibraryTrace[name]}
+}
+
+// Options of a program called from a running program, it shares the limits of the caller
+func nestedProgramRunOptions(ctx *RunContext, name string) ProgramRunOptions {
+	options := LibraryProgramRunOptions(name)
+	options.run = ctx.options.run
+	options.depth = ctx.options.depth + 1
+	options.dryRun = ctx.options.dryRun
+	if ctx.options.trace != nil {
+		options.trace = ctx.options.trace
+	}
+	return options
+}
+
+func ExecuteCommandsWithOptions(program string, contextVariables map[string]string, relatedPanels *[]string, options ProgramRunOptions) map[string]string {
 	returnValues := map[string]string{}
 	returnValues["Return"] = ""
 	cmds := strings.Split(program, "\n")
 	ip := 0
 	cmdCount := len(cmds)
-	var ctx RunContext = RunContext{map[string]string{}, map[string]JsonHttpQuery{}, []int{}, []int{}}
+	var ctx RunContext = RunContext{map[string]string{}, map[string]JsonHttpQuery{}, []int{}, []int{}, newHttpRequestSpec(),
+		options, []TryBlock{}, 0, false, "", 0, -1, 0, "", 0, false}
 	ctx.variables = contextVariables
 	AddBaseVariables(&ctx)
 
-	for ip < cmdCount {
+	if ctx.options.Name == "" {
+		ctx.options.Name = contextVariables["ActionPanel.Title"]
+	}
+	if ctx.options.run == nil {
+		ctx.options.run = registerRunningScript(ctx.options.Name)
+		defer unregisterRunningScript(ctx.options.run)
+	}
+	if ScriptMaxNesting > 0 && ctx.options.depth > ScriptMaxNesting {
+		abortExecution(&ctx, fmt.Sprintf("Nesting limit (%d) exceeded", ScriptMaxNesting))
+		returnValues["Error"] = ctx.lastError
+		returnValues["Aborted"] = "true"
+		return returnValues
+	}
+	var ownTrace *ScriptTrace = nil
+	if ctx.options.Trace && ctx.options.trace == nil {
+		ownTrace = startScriptTrace(ctx.options.Name)
+		ctx.options.trace = ownTrace
+	}
+
+	for {
+		if ctx.errorRaised && !HandleRaisedError(&ctx, cmds, &ip) {
+			break
+		}
+		if ip >= cmdCount {
+			break
+		}
+		ctx.ip = ip
 		cmd := strings.TrimSpace(cmds[ip])
 
 		if cmd == "" {
@@ -46,6 +127,11 @@ func ExecuteCommands(program string, contextVariables map[string]string, related
 			continue
 		}
 
+		if reason := ctx.options.run.step(); reason != "" {
+			abortExecution(&ctx, reason)
+			break
+		}
+
 		if strings.HasPrefix(cmd, "If ") {
 			Command_If(&ctx, cmd[3:])
 			ip++
@@ -71,12 +157,29 @@ func ExecuteCommands(program string, contextVariables map[string]string, related
 			ip++
 			continue
 		}
+		if cmd == "Try" {
+			Command_Try(&ctx, ip)
+			ip++
+			continue
+		}
+		if cmd == "Catch" {
+			Command_Catch(&ctx, cmds, &ip)
+			ip++
+			continue
+		}
+		if cmd == "EndTry" {
+			Command_EndTry(&ctx)
+			ip++
+			continue
+		}
 
 		if ctx.iwblocks.HasZeroElement() {
 			ip++
 			continue
 		}
 
+		traceLine(&ctx, cmd)
+
 		if cmd == "Return" {
 			returnValues["Return"] = ""
 			break
@@ -93,8 +196,24 @@ func ExecuteCommands(program string, contextVariables map[string]string, related
 			continue
 		}
 
+		if strings.HasPrefix(cmd, "Error ") {
+			Command_Error(&ctx, cmd[6:])
+			ip++
+			continue
+		}
+		if cmd == "Error" {
+			Command_Error(&ctx, "")
+			ip++
+			continue
+		}
+		if strings.HasPrefix(cmd, "OnError ") {
+			ctx.options.OnError = strings.TrimSpace(cmd[8:])
+			ip++
+			continue
+		}
+
 		if strings.HasPrefix(cmd, "Run ") {
-			Command_Run(ctx, cmd[4:], relatedPanels)
+			Command_Run(&ctx, cmd[4:], relatedPanels)
 			ip++
 			continue
 		}
@@ -103,6 +222,56 @@ func ExecuteCommands(program string, contextVariables map[string]string, related
 			ip++
 			continue
 		}
+		if strings.HasPrefix(cmd, "RunAsync ") {
+			Command_RunAsync(&ctx, cmd[9:])
+			ip++
+			continue
+		}
+		if strings.HasPrefix(cmd, "Spawn ") {
+			Command_Spawn(&ctx, cmd[6:])
+			ip++
+			continue
+		}
+		if strings.HasPrefix(cmd, "IsJobRunning ") {
+			Command_IsJobRunning(&ctx, cmd[13:])
+			ip++
+			continue
+		}
+		if strings.HasPrefix(cmd, "KillJob ") {
+			Command_KillJob(&ctx, cmd[8:])
+			ip++
+			continue
+		}
+		if strings.HasPrefix(cmd, "StartTimer ") {
+			Command_StartTimer(&ctx, cmd[11:], false)
+			ip++
+			continue
+		}
+		if strings.HasPrefix(cmd, "RestartTimer ") {
+			Command_StartTimer(&ctx, cmd[13:], true)
+			ip++
+			continue
+		}
+		if strings.HasPrefix(cmd, "CancelTimer ") {
+			Command_CancelTimer(&ctx, cmd[12:])
+			ip++
+			continue
+		}
+		if strings.HasPrefix(cmd, "TimerRemaining ") {
+			Command_TimerRemaining(&ctx, cmd[15:])
+			ip++
+			continue
+		}
+		if strings.HasPrefix(cmd, "Lock ") {
+			Command_Lock(&ctx, cmd[5:])
+			ip++
+			continue
+		}
+		if strings.HasPrefix(cmd, "Unlock ") {
+			Command_Unlock(&ctx, cmd[7:])
+			ip++
+			continue
+		}
 		if strings.HasPrefix(cmd, "PrintConsole ") {
 			Command_PrintConsole(ctx, cmd[13:])
 			ip++
@@ -178,6 +347,41 @@ func ExecuteCommands(program string, contextVariables map[string]string, related
 			ip++
 			continue
 		}
+		if strings.HasPrefix(cmd, "CallHttpEx ") {
+			Command_CallHttpEx(&ctx, cmd[11:])
+			ip++
+			continue
+		}
+		if strings.HasPrefix(cmd, "HttpHeader ") {
+			Command_HttpHeader(&ctx, cmd[11:])
+			ip++
+			continue
+		}
+		if strings.HasPrefix(cmd, "HttpBody ") {
+			Command_HttpBody(&ctx, cmd[9:], false)
+			ip++
+			continue
+		}
+		if strings.HasPrefix(cmd, "HttpBodyAppend ") {
+			Command_HttpBody(&ctx, cmd[15:], true)
+			ip++
+			continue
+		}
+		if strings.HasPrefix(cmd, "HttpTimeout ") {
+			Command_HttpTimeout(&ctx, cmd[12:])
+			ip++
+			continue
+		}
+		if strings.HasPrefix(cmd, "HttpBasicAuth ") {
+			Command_HttpBasicAuth(&ctx, cmd[14:])
+			ip++
+			continue
+		}
+		if strings.HasPrefix(cmd, "HttpBearerAuth ") {
+			Command_HttpBearerAuth(&ctx, cmd[15:])
+			ip++
+			continue
+		}
 		if strings.HasPrefix(cmd, "SetFromJsonReq ") {
 			Command_SetFromJsonReq(&ctx, cmd[15:])
 			ip++
@@ -198,6 +402,21 @@ func ExecuteCommands(program string, contextVariables map[string]string, related
 			ip++
 			continue
 		}
+		if strings.HasPrefix(cmd, "Panel ") {
+			Command_Panel(&ctx, cmd[6:], relatedPanels)
+			ip++
+			continue
+		}
+		if strings.HasPrefix(cmd, "PresenceSimulation ") {
+			Command_PresenceSimulation(&ctx, cmd[19:])
+			ip++
+			continue
+		}
+		if strings.HasPrefix(cmd, "SetScheduleProfile ") {
+			Command_SetScheduleProfile(&ctx, cmd[19:], relatedPanels)
+			ip++
+			continue
+		}
 		if strings.HasPrefix(cmd, "SetSchedule ") {
 			Command_SetSchedule(&ctx, cmd[12:])
 			ip++
@@ -229,8 +448,7 @@ func ExecuteCommands(program string, contextVariables map[string]string, related
 			continue
 		}
 
-		fmt.Println("--------- Script error---------\nUnknown command: ", cmd)
-
+		RaiseError(&ctx, "Unknown command: "+cmd)
 		ip++
 	}
 
@@ -240,12 +458,35 @@ func ExecuteCommands(program string, contextVariables map[string]string, related
 		}
 	}
 
+	if ctx.lastError != "" {
+		returnValues["Error"] = ctx.lastError
+		returnValues["Error.Line"] = fmt.Sprintf("%d", ctx.lastErrorIp+1)
+	}
+	if ctx.aborted {
+		returnValues["Aborted"] = "true"
+	}
+
+	if ownTrace != nil {
+		result := "ok"
+		if ctx.lastError != "" {
+			result = fmt.Sprintf("Error at line %d: %s", ctx.lastErrorIp+1, ctx.lastError)
+		}
+		ownTrace.finish(result)
+	}
+
 	return returnValues
 }
 
 func SetVariable(ctx *RunContext, name string, value string) {
+	traceVariable(ctx, name, value)
 	if strings.HasPrefix(name, "state.") {
-		GlowdashStateVariables[name[6:]] = value
+		if ctx.options.dryRun != nil {
+			ctx.options.dryRun.record(ctx, "Set "+name+" = "+value)
+			ctx.options.dryRun.setStateVariable(name[6:], value)
+			return
+		}
+		oldValue := setStateVariable(name[6:], value)
+		AutomationStateVariableUpdate(name[6:], oldValue, value)
 		return
 	}
 	ctx.variables[name] = value
@@ -253,7 +494,7 @@ func SetVariable(ctx *RunContext, name string, value string) {
 
 func GetVariable(ctx *RunContext, name string, fallback string) string {
 	if strings.HasPrefix(name, "state.") {
-		if val, ok := GlowdashStateVariables[name[6:]]; ok {
+		if val, ok := ctxGetStateVariable(ctx, name[6:]); ok {
 			return val
 		}
 		return fallback
@@ -270,13 +511,20 @@ func ResolveVariables(ctx RunContext, str string) string {
 		for name, value := range ctx.variables {
 			rstr = strings.Replace(rstr, "{{"+name+"}}", value, -1)
 		}
-		for name, value := range GlowdashStateVariables {
+		for name, value := range ctxStateVariablesSnapshot(&ctx) {
 			rstr = strings.Replace(rstr, "{{state."+name+"}}", value, -1)
 		}
 	}
 	return rstr
 }
 
+// Evaluates a condition expression (like the If command) out of a program
+func EvalConditionExpression(expression string, contextVariables map[string]string) bool {
+	ctx := RunContext{variables: contextVariables, jqrvariables: map[string]JsonHttpQuery{}, httpreq: newHttpRequestSpec(), retryIp: -1}
+	AddBaseVariables(&ctx)
+	return EvalExpressionBool(ctx, strings.TrimSpace(expression))
+}
+
 func EvalExpressionBool(ctx RunContext, cmdpart string) bool {
 	parts := strings.Split(cmdpart, " ")
 	if len(parts) == 3 {
@@ -319,7 +567,7 @@ func EvalExpressionBool1op(ctx RunContext, parts []string) bool {
 	if op == "isDefined" {
 		vname := strings.TrimSpace(parts[1])
 		if strings.HasPrefix(vname, "state.") {
-			if _, ok := GlowdashStateVariables[vname[6:]]; ok {
+			if _, ok := ctxGetStateVariable(&ctx, vname[6:]); ok {
 				return true
 			}
 			return false
@@ -332,7 +580,7 @@ func EvalExpressionBool1op(ctx RunContext, parts []string) bool {
 	if op == "isNotDefined" {
 		vname := strings.TrimSpace(parts[1])
 		if strings.HasPrefix(vname, "state.") {
-			if _, ok := GlowdashStateVariables[vname[6:]]; !ok {
+			if _, ok := ctxGetStateVariable(&ctx, vname[6:]); !ok {
 				return true
 			}
 			return false
@@ -445,6 +693,7 @@ func Command_If(ctx *RunContext, cmdpart string) {
 	if EvalExpressionBool(*ctx, cmdpart) {
 		pushval = 1
 	}
+	traceCondition(ctx, "If", cmdpart, pushval == 1)
 	ctx.iwblocks.Push(pushval)
 }
 
@@ -474,6 +723,7 @@ func Command_While(ctx *RunContext, cmdpart string, ip int) {
 	if EvalExpressionBool(*ctx, cmdpart) {
 		pushval = 1
 	}
+	traceCondition(ctx, "While", cmdpart, pushval == 1)
 	ctx.iwblocks.Push(pushval)
 	ctx.whileblocks.Push(ip)
 }
@@ -485,34 +735,359 @@ func Command_EndWhile(ctx *RunContext, ip *int) {
 	ctx.iwblocks.Pop()
 }
 
-func Command_Run(ctx RunContext, cmdpart string, relatedPanels *[]string) {
-	code, ok := ProgramLibrary[cmdpart]
-	if ok {
-		ExecuteCommands(code, ctx.variables, relatedPanels)
+func Command_Try(ctx *RunContext, ip int) {
+	ctx.tryblocks = append(ctx.tryblocks, TryBlock{
+		ip:         ip,
+		iwdepth:    len(ctx.iwblocks),
+		whiledepth: len(ctx.whileblocks),
+		skipped:    ctx.iwblocks.HasZeroElement(),
+		inCatch:    false,
+	})
+}
+
+func Command_Catch(ctx *RunContext, cmds []string, ip *int) {
+	if len(ctx.tryblocks) == 0 {
+		return
+	}
+	tb := ctx.tryblocks[len(ctx.tryblocks)-1]
+	if tb.skipped || tb.inCatch {
+		return
+	}
+	// The Try block finished without error, the Catch block has to be jumped over
+	ctx.tryblocks = ctx.tryblocks[:len(ctx.tryblocks)-1]
+	*ip = findTryBlockPart(cmds, tb.ip, "EndTry")
+}
+
+func Command_EndTry(ctx *RunContext) {
+	if len(ctx.tryblocks) > 0 {
+		ctx.tryblocks = ctx.tryblocks[:len(ctx.tryblocks)-1]
+	}
+}
+
+func Command_Error(ctx *RunContext, cmdpart string) {
+	msg := strings.TrimSpace(ResolveVariables(*ctx, cmdpart))
+	if len(msg) >= 2 && strings.HasPrefix(msg, "\"") && strings.HasSuffix(msg, "\"") {
+		msg = msg[1 : len(msg)-1]
+	}
+	if msg == "" {
+		msg = "Error raised by program"
+	}
+	RaiseError(ctx, msg)
+}
+
+// Sets the error state of the program, the error is handled before the next command is executed
+func RaiseError(ctx *RunContext, message string) {
+	traceError(ctx, message)
+	ctx.errorRaised = true
+	ctx.errorMessage = message
+	ctx.errorIp = ctx.ip
+}
+
+// Returns the line index of the Catch or EndTry belongs to the Try located in tryIp.
+// If the Catch is not found it returns -1, if the EndTry is missing it returns the end of the program.
+func findTryBlockPart(cmds []string, tryIp int, part string) int {
+	depth := 0
+	for i := tryIp + 1; i < len(cmds); i++ {
+		c := strings.TrimSpace(cmds[i])
+		if c == "Try" {
+			depth++
+		}
+		if c == "Catch" && depth == 0 && part == "Catch" {
+			return i
+		}
+		if c == "EndTry" {
+			if depth == 0 {
+				if part == "EndTry" {
+					return i
+				}
+				return -1
+			}
+			depth--
+		}
+	}
+	if part == "EndTry" {
+		return len(cmds)
+	}
+	return -1
+}
+
+func parseOnErrorPolicy(policy string) (string, int) {
+	parts := strings.Fields(strings.ToLower(policy))
+	if len(parts) == 0 {
+		return "continue", 0
+	}
+	if parts[0] == "abort" {
+		return "abort", 0
+	}
+	if parts[0] == "retry" {
+		retries := 1
+		if len(parts) > 1 {
+			if r, err := strconv.Atoi(parts[1]); err == nil && r >= 0 {
+				retries = r
+			}
+		}
+		return "retry", retries
+	}
+	return "continue", 0
+}
+
+// Handles the raised error according to the Try blocks and the OnError policy.
+// Returns false if the execution of the program have to be stopped.
+func HandleRaisedError(ctx *RunContext, cmds []string, ip *int) bool {
+	ctx.errorRaised = false
+	ctx.variables["Error.Message"] = ctx.errorMessage
+	ctx.variables["Error.Line"] = fmt.Sprintf("%d", ctx.errorIp+1)
+	ctx.variables["Error.Command"] = ""
+	if ctx.errorIp < len(cmds) {
+		ctx.variables["Error.Command"] = strings.TrimSpace(cmds[ctx.errorIp])
+	}
+
+	for i := len(ctx.tryblocks) - 1; i >= 0; i-- {
+		tb := ctx.tryblocks[i]
+		if tb.skipped || tb.inCatch {
+			continue
+		}
+		ctx.tryblocks = ctx.tryblocks[:i+1]
+		ctx.iwblocks = ctx.iwblocks[:tb.iwdepth]
+		ctx.whileblocks = ctx.whileblocks[:tb.whiledepth]
+		catchIp := findTryBlockPart(cmds, tb.ip, "Catch")
+		if catchIp < 0 {
+			ctx.tryblocks = ctx.tryblocks[:i]
+			*ip = findTryBlockPart(cmds, tb.ip, "EndTry") + 1
+			return true
+		}
+		ctx.tryblocks[i].inCatch = true
+		*ip = catchIp + 1
+		return true
+	}
+
+	mode, retries := parseOnErrorPolicy(ctx.options.OnError)
+	if mode == "retry" {
+		if ctx.retryIp != ctx.errorIp {
+			ctx.retryIp = ctx.errorIp
+			ctx.retryCount = 0
+		}
+		if ctx.retryCount < retries {
+			ctx.retryCount++
+			if DebugLevel > 1 {
+				fmt.Printf("Retry (%d/%d) line %d of program \"%s\": %s\n",
+					ctx.retryCount, retries, ctx.errorIp+1, ctx.options.Name, ctx.errorMessage)
+			}
+			*ip = ctx.errorIp
+			return true
+		}
+	}
+
+	ctx.lastError = ctx.errorMessage
+	ctx.lastErrorIp = ctx.errorIp
+	logScriptError(ctx)
+	if mode == "continue" {
+		return true
+	}
+	ctx.aborted = true
+	return false
+}
+
+// Stops the program because of a limit or a kill request, it can not be caught by Try blocks
+func abortExecution(ctx *RunContext, reason string) {
+	traceError(ctx, reason)
+	ctx.errorMessage = reason
+	ctx.errorIp = ctx.ip
+	ctx.lastError = reason
+	ctx.lastErrorIp = ctx.ip
+	ctx.aborted = true
+	if ctx.options.depth == 0 {
+		logScriptError(ctx)
+	}
+}
+
+func logScriptError(ctx *RunContext) {
+	name := ctx.options.Name
+	if ctx.options.dryRun != nil {
+		ctx.options.dryRun.record(ctx, fmt.Sprintf("ERROR at line %d: %s", ctx.errorIp+1, ctx.errorMessage))
+		return
+	}
+	fmt.Printf("--------- Script error---------\nProgram \"%s\" line %d: %s\n", name, ctx.errorIp+1, ctx.errorMessage)
+	GlowdashConsole.Write(T("ERROR: Program \"{{program}}\" failed at line {{line}}: {{message}}",
+		map[string]any{"program": html.EscapeString(name), "line": ctx.errorIp + 1, "message": html.EscapeString(ctx.errorMessage)}))
+}
+
+func Command_Run(ctx *RunContext, cmdpart string, relatedPanels *[]string) {
+	name, code, ok := resolveLibraryProgram(ctx, cmdpart)
+	if !ok {
+		RaiseError(ctx, "Unknown program: "+cmdpart)
+		return
+	}
+	results := ExecuteCommandsWithOptions(code, ctx.variables, relatedPanels, nestedProgramRunOptions(ctx, name))
+	if results["Aborted"] == "true" {
+		RaiseError(ctx, results["Error"])
 	}
 }
 
 func Command_RunSet(ctx *RunContext, cmdpart string, relatedPanels *[]string) {
 	parts := strings.Split(cmdpart, " ")
 	if len(parts) == 2 {
-		code, ok := ProgramLibrary[parts[1]]
+		name, code, ok := resolveLibraryProgram(ctx, parts[1])
+		if !ok {
+			RaiseError(ctx, "Unknown program: "+parts[1])
+			return
+		}
+		results := ExecuteCommandsWithOptions(code, ctx.variables, relatedPanels, nestedProgramRunOptions(ctx, name))
+		SetVariable(ctx, parts[0], results["Return"])
+		if results["Aborted"] == "true" {
+			RaiseError(ctx, results["Error"])
+		}
+	}
+}
+
+// Starts the library program in background with the copy of the current variables
+func spawnLibraryProgram(ctx *RunContext, programName string) (int, bool) {
+	name, code, ok := resolveLibraryProgram(ctx, programName)
+	if !ok {
+		RaiseError(ctx, "Unknown program: "+programName)
+		return 0, false
+	}
+	if dryRunSkip(ctx, "RunAsync "+name) {
+		return 0, true
+	}
+	variables := map[string]string{}
+	for n, v := range ctx.variables {
+		variables[n] = v
+	}
+	return startBackgroundProgram(code, variables, LibraryProgramRunOptions(name), nil), true
+}
+
+func Command_RunAsync(ctx *RunContext, cmdpart string) {
+	spawnLibraryProgram(ctx, strings.TrimSpace(ResolveVariables(*ctx, cmdpart)))
+}
+
+func Command_Spawn(ctx *RunContext, cmdpart string) {
+	parts := strings.Split(cmdpart, " ")
+	if len(parts) == 2 {
+		jobId, ok := spawnLibraryProgram(ctx, ResolveVariables(*ctx, parts[1]))
 		if ok {
-			results := ExecuteCommands(code, ctx.variables, relatedPanels)
-			SetVariable(ctx, parts[0], results["Return"])
+			SetVariable(ctx, parts[0], fmt.Sprintf("%d", jobId))
 		}
 	}
 }
 
+func Command_IsJobRunning(ctx *RunContext, cmdpart string) {
+	parts := strings.Split(cmdpart, " ")
+	if len(parts) == 2 {
+		jobId, err := strconv.Atoi(ResolveVariables(*ctx, parts[1]))
+		SetVariable(ctx, parts[0], fmt.Sprintf("%t", err == nil && isRunningScript(jobId)))
+	}
+}
+
+func Command_KillJob(ctx *RunContext, cmdpart string) {
+	jobId, err := strconv.Atoi(strings.TrimSpace(ResolveVariables(*ctx, cmdpart)))
+	if dryRunSkip(ctx, "KillJob "+ResolveVariables(*ctx, cmdpart)) {
+		return
+	}
+	if err == nil {
+		killRunningScript(jobId)
+	}
+}
+
+/* Handler of following commands:
+*	StartTimer <name> <seconds> <programname>
+*	RestartTimer <name> <seconds> <programname> */
+func Command_StartTimer(ctx *RunContext, cmdpart string, restart bool) {
+	parts := strings.Split(ResolveVariables(*ctx, cmdpart), " ")
+	if len(parts) != 3 {
+		RaiseError(ctx, "Wrong parameters of timer command: "+cmdpart)
+		return
+	}
+	// The delay must fit into time.Duration, the NaN, Inf and non-positive values are refused
+	seconds, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil || math.IsNaN(seconds) || seconds <= 0 || seconds >= float64(math.MaxInt64)/float64(time.Second) {
+		RaiseError(ctx, "Wrong timer delay: "+parts[1])
+		return
+	}
+	name, _, ok := resolveLibraryProgram(ctx, parts[2])
+	if !ok {
+		RaiseError(ctx, "Unknown program: "+parts[2])
+		return
+	}
+	parts[2] = name
+	if dryRunSkip(ctx, IfTrue(restart, "RestartTimer ")+IfTrue(!restart, "StartTimer ")+strings.Join(parts, " ")) {
+		return
+	}
+	startScriptTimer(parts[0], time.Duration(seconds*float64(time.Second)), parts[2], restart)
+}
+
+func Command_CancelTimer(ctx *RunContext, cmdpart string) {
+	if dryRunSkip(ctx, "CancelTimer "+ResolveVariables(*ctx, cmdpart)) {
+		return
+	}
+	cancelScriptTimer(strings.TrimSpace(ResolveVariables(*ctx, cmdpart)))
+}
+
+func Command_TimerRemaining(ctx *RunContext, cmdpart string) {
+	parts := strings.Split(cmdpart, " ")
+	if len(parts) == 2 {
+		remaining, running := scriptTimerRemaining(ResolveVariables(*ctx, parts[1]))
+		SetVariable(ctx, parts[0], fmt.Sprintf("%d", int(math.Ceil(remaining.Seconds()))))
+		SetVariable(ctx, parts[0]+".Running", fmt.Sprintf("%t", running))
+	}
+}
+
+/* Handler of following command:
+*	Lock <name> [<timeout ms>] */
+func Command_Lock(ctx *RunContext, cmdpart string) {
+	parts := strings.Split(ResolveVariables(*ctx, cmdpart), " ")
+	if len(parts) < 1 || len(parts) > 2 || parts[0] == "" {
+		RaiseError(ctx, "Wrong parameters of Lock command: "+cmdpart)
+		return
+	}
+	timeout := 0
+	if len(parts) == 2 {
+		var err error
+		timeout, err = strconv.Atoi(parts[1])
+		if err != nil || timeout < 0 {
+			RaiseError(ctx, "Wrong lock timeout: "+parts[1])
+			return
+		}
+	}
+	if dryRunSkip(ctx, "Lock "+strings.Join(parts, " ")) {
+		return
+	}
+	if err := acquireScriptLock(ctx.options.run, parts[0], time.Duration(timeout)*time.Millisecond); err != nil {
+		RaiseError(ctx, "Cannot acquire lock "+parts[0]+": "+err.Error())
+	}
+}
+
+func Command_Unlock(ctx *RunContext, cmdpart string) {
+	name := strings.TrimSpace(ResolveVariables(*ctx, cmdpart))
+	if dryRunSkip(ctx, "Unlock "+name) {
+		return
+	}
+	if !releaseScriptLock(ctx.options.run, name) {
+		RaiseError(ctx, "Lock is not held: "+name)
+	}
+}
+
 func Command_PrintConsole(ctx RunContext, cmdpart string) {
+	if ctx.options.dryRun != nil {
+		ctx.options.dryRun.record(&ctx, "PrintConsole: "+ResolveVariables(ctx, cmdpart))
+		return
+	}
 	fmt.Println("ACTION-CONSOLE> " + ResolveVariables(ctx, cmdpart))
 }
 
 func Command_PrintGlowdashConsole(ctx RunContext, cmdpart string) {
+	if ctx.options.dryRun != nil {
+		ctx.options.dryRun.record(&ctx, "PrintGlowdashConsole: "+ResolveVariables(ctx, cmdpart))
+		return
+	}
 	GlowdashConsole.Write("&gt;&gt;&gt; " + ResolveVariables(ctx, cmdpart))
 }
 
 func Command_PrintVariablesConsole(ctx RunContext) {
-	for n, v := range GlowdashStateVariables {
+	if dryRunSkip(&ctx, "PrintVariablesConsole") {
+		return
+	}
+	for n, v := range stateVariablesSnapshot() {
 		fmt.Println("ACTION-CONSOLE> state." + n + " = " + v)
 	}
 	for n, v := range ctx.variables {
@@ -521,7 +1096,10 @@ func Command_PrintVariablesConsole(ctx RunContext) {
 }
 
 func Command_PrintVariablesGlowdashConsole(ctx RunContext) {
-	for n, v := range GlowdashStateVariables {
+	if dryRunSkip(&ctx, "PrintVariablesGlowdashConsole") {
+		return
+	}
+	for n, v := range stateVariablesSnapshot() {
 		GlowdashConsole.Write("&gt;&gt;&gt; state." + n + " = " + v)
 	}
 	for n, v := range ctx.variables {
@@ -641,8 +1219,11 @@ func Command_AddMinutesToTime(ctx *RunContext, cmdpart string) {
 
 func Command_WaitMs(ctx *RunContext, cmdpart string) {
 	msval, err := strconv.Atoi(ResolveVariables(*ctx, cmdpart))
+	if dryRunSkip(ctx, "WaitMs "+ResolveVariables(*ctx, cmdpart)) {
+		return
+	}
 	if err == nil {
-		time.Sleep(time.Millisecond * time.Duration(msval))
+		ctx.options.run.wait(time.Millisecond * time.Duration(msval))
 	}
 }
 
@@ -659,11 +1240,11 @@ func Command_Set(ctx *RunContext, cmdpart string) {
 func Command_SetFromJsonReq(ctx *RunContext, cmdpart string) {
 	parts := strings.Split(cmdpart, " ")
 	if len(parts) == 3 {
-		jhq := execJsonHttpQuery(ResolveVariables(*ctx, parts[1]))
+		jhq := ctxJsonHttpQuery(ctx, "SetFromJsonReq", ResolveVariables(*ctx, parts[1]))
 		if jhq.Success {
 			ctx.variables["LastHttpCallSuccess"] = "true"
 		} else {
-			ctx.variables["LastHttpCallSuccess"] = "false"
+			callFailed(ctx, "LastHttpCallSuccess", "Http call failed: "+strings.TrimSpace(jhq.ErrorMessage))
 		}
 
 		_, typestr := jhq.SmartJSON.GetNodeByPath(parts[2])
@@ -692,7 +1273,7 @@ func Command_SetFromStoredJson(ctx *RunContext, cmdpart string) {
 	if len(parts) == 3 {
 		jhq, ok := ctx.jqrvariables[parts[1]]
 		if !ok {
-			fmt.Println("--------- Script error---------\nUnknown json result variable: ", parts[1])
+			RaiseError(ctx, "Unknown json result variable: "+parts[1])
 			return
 		}
 		_, typestr := jhq.SmartJSON.GetNodeByPath(parts[2])
@@ -718,6 +1299,9 @@ func Command_SetFromStoredJson(ctx *RunContext, cmdpart string) {
 
 func Command_SetSchedule(ctx *RunContext, cmdpart string) {
 	rc := ResolveVariables(*ctx, cmdpart)
+	if dryRunSkip(ctx, "SetSchedule "+rc) {
+		return
+	}
 	if strings.HasPrefix(rc, "on ") {
 		SetScheduleOnOffByName(rc[3:], true)
 	}
@@ -726,11 +1310,38 @@ func Command_SetSchedule(ctx *RunContext, cmdpart string) {
 	}
 }
 
+func Command_SetScheduleProfile(ctx *RunContext, cmdpart string, relatedPanels *[]string) {
+	rc := strings.TrimSpace(ResolveVariables(*ctx, cmdpart))
+	if !isScheduleProfile(rc) {
+		RaiseError(ctx, "Unknown schedule profile: "+rc)
+		return
+	}
+	if dryRunSkip(ctx, "SetScheduleProfile "+rc) {
+		return
+	}
+	SetActiveScheduleProfile(rc)
+	if ids := scheduleProfilePanelIds(); len(ids) > 0 {
+		*relatedPanels = append(*relatedPanels, "Updated "+strings.Join(ids, " "))
+	}
+}
+
+func Command_PresenceSimulation(ctx *RunContext, cmdpart string) {
+	rc := strings.TrimSpace(ResolveVariables(*ctx, cmdpart))
+	if rc != "on" && rc != "off" {
+		RaiseError(ctx, "Wrong parameter of PresenceSimulation command: "+rc)
+		return
+	}
+	if dryRunSkip(ctx, "PresenceSimulation "+rc) {
+		return
+	}
+	SetPresenceSimulation(rc == "on")
+}
+
 func Command_AddOneshotSchedule(ctx *RunContext, cmdpart string) {
 	rc := ResolveVariables(*ctx, cmdpart)
 	s := Schedule{}
 	s.name = T("Generated schedule on {{timestr}}",
-		map[string]any{"timestr": time.Now().Format("2006-01-02 15:04:05")})
+		map[string]any{"timestr": Now().Format("2006-01-02 15:04:05")})
 	s.enabled = true
 	s.oneshot = true
 
@@ -742,49 +1353,165 @@ func Command_AddOneshotSchedule(ctx *RunContext, cmdpart string) {
 	s.daySat = true
 	s.daySun = true
 
-	var panelId string
-	var actionParam string
-	var reqhour float64
-	var reqmin float64
-
-	n, err := fmt.Sscanf(rc, "%s %s %f:%f", &panelId, &actionParam, &reqhour, &reqmin)
-	if err != nil || n != 4 {
-		fmt.Println("--------- Script parse error---------\nError in AddOneshotSchedule parameters: ", rc)
+	parts := strings.Fields(rc)
+	if len(parts) != 3 && len(parts) != 5 {
+		RaiseError(ctx, "Error in AddOneshotSchedule parameters: "+rc)
 		return
 	}
+	if len(parts) == 5 {
+		duration, err := strconv.Atoi(parts[3])
+		if err != nil || duration < 1 || duration > scheduleMaxDuration {
+			RaiseError(ctx, "Wrong duration in AddOneshotSchedule: "+parts[3])
+			return
+		}
+		s.duration = duration
+		s.endAction = parts[4]
+	}
+	panelId := parts[0]
+	actionParam := parts[1]
 
-	s.hour = int(reqhour)
-	s.min = int(reqmin)
+	if event, offset, isSun := ParseSunTimeSpec(parts[2]); isSun {
+		s.timeRef = event
+		s.timeOffset = offset
+	} else {
+		var reqhour, reqmin int
+		n, err := fmt.Sscanf(parts[2], "%d:%d", &reqhour, &reqmin)
+		if err != nil || n != 2 {
+			RaiseError(ctx, "Error in AddOneshotSchedule parameters: "+rc)
+			return
+		}
+		s.hour = reqhour
+		s.min = reqmin
+	}
 
 	s.actionType = getScheduleActionTypeByPanelId(panelId)
 	if s.actionType != "" {
 		s.actionId = panelId
 		s.actionParam = actionParam
+		if dryRunSkip(ctx, "AddOneshotSchedule "+rc) {
+			return
+		}
 		addSchedule(s)
 	} else {
-		fmt.Println("--------- Script error---------\nUnknown panel id in AddOneshotSchedule: ", panelId)
+		RaiseError(ctx, "Unknown panel id in AddOneshotSchedule: "+panelId)
 	}
 }
 
 func Command_CallHttp(ctx *RunContext, cmdpart string) {
-	ro := execJsonHttpQuery(ResolveVariables(*ctx, cmdpart))
+	ro := ctxJsonHttpQuery(ctx, "CallHttp", ResolveVariables(*ctx, cmdpart))
 	if ro.Success {
 		ctx.variables["LastHttpCallSuccess"] = "true"
 	} else {
-		ctx.variables["LastHttpCallSuccess"] = "false"
+		callFailed(ctx, "LastHttpCallSuccess", "Http call failed: "+strings.TrimSpace(ro.ErrorMessage))
 	}
 }
 
 func Command_CallHttpStoreJson(ctx *RunContext, cmdpart string) {
 	parts := strings.Split(cmdpart, " ")
 	if len(parts) == 2 {
-		ctx.jqrvariables[parts[0]] = execJsonHttpQuery(ResolveVariables(*ctx, parts[1]))
+		ctx.jqrvariables[parts[0]] = ctxJsonHttpQuery(ctx, "CallHttpStoreJson", ResolveVariables(*ctx, parts[1]))
 		if ctx.jqrvariables[parts[0]].Success {
 			ctx.variables["LastHttpCallSuccess"] = "true"
 		} else {
-			ctx.variables["LastHttpCallSuccess"] = "false"
+			callFailed(ctx, "LastHttpCallSuccess", "Http call failed: "+strings.TrimSpace(ctx.jqrvariables[parts[0]].ErrorMessage))
+		}
+	}
+}
+
+// Sets the success variable of the device call to false and raises an error
+func callFailed(ctx *RunContext, successVariable string, message string) {
+	ctx.variables[successVariable] = "false"
+	RaiseError(ctx, message)
+}
+
+func newHttpRequestSpec() HttpRequestSpec {
+	return HttpRequestSpec{
+		Method:      "GET",
+		Url:         "",
+		Headers:     map[string]string{},
+		Body:        "",
+		Timeout:     0,
+		AuthUser:    "",
+		AuthPass:    "",
+		BearerToken: "",
+	}
+}
+
+func Command_HttpHeader(ctx *RunContext, cmdpart string) {
+	parts := strings.SplitN(ResolveVariables(*ctx, cmdpart), " ", 2)
+	if len(parts) == 2 {
+		ctx.httpreq.Headers[strings.TrimSuffix(parts[0], ":")] = parts[1]
+	}
+}
+
+func Command_HttpBody(ctx *RunContext, cmdpart string, appendLine bool) {
+	if appendLine && ctx.httpreq.Body != "" {
+		ctx.httpreq.Body += "\n" + ResolveVariables(*ctx, cmdpart)
+		return
+	}
+	ctx.httpreq.Body = ResolveVariables(*ctx, cmdpart)
+}
+
+func Command_HttpTimeout(ctx *RunContext, cmdpart string) {
+	msval, err := strconv.Atoi(strings.TrimSpace(ResolveVariables(*ctx, cmdpart)))
+	if err == nil && msval > 0 {
+		ctx.httpreq.Timeout = time.Millisecond * time.Duration(msval)
+	}
+}
+
+func Command_HttpBasicAuth(ctx *RunContext, cmdpart string) {
+	parts := strings.SplitN(ResolveVariables(*ctx, cmdpart), " ", 2)
+	if len(parts) == 2 {
+		ctx.httpreq.AuthUser = parts[0]
+		ctx.httpreq.AuthPass = parts[1]
+	}
+}
+
+func Command_HttpBearerAuth(ctx *RunContext, cmdpart string) {
+	ctx.httpreq.BearerToken = strings.TrimSpace(ResolveVariables(*ctx, cmdpart))
+}
+
+/* Handler of following command:
+*	CallHttpEx <variable> <method> <url>
+*  The headers, body, timeout and authentication set by the HttpHeader, HttpBody, HttpBodyAppend,
+*  HttpTimeout, HttpBasicAuth, HttpBearerAuth commands are used and cleared after the call. */
+func Command_CallHttpEx(ctx *RunContext, cmdpart string) {
+	parts := strings.Split(cmdpart, " ")
+	if len(parts) != 3 {
+		ctx.httpreq = newHttpRequestSpec()
+		callFailed(ctx, "LastHttpCallSuccess", "Wrong parameters of CallHttpEx: "+cmdpart)
+		return
+	}
+
+	spec := ctx.httpreq
+	spec.Method = ResolveVariables(*ctx, parts[1])
+	spec.Url = ResolveVariables(*ctx, parts[2])
+	ctx.httpreq = newHttpRequestSpec()
+
+	hqr := ctxHttpRequest(ctx, spec)
+
+	vname := parts[0]
+	SetVariable(ctx, vname, string(hqr.Body))
+	SetVariable(ctx, vname+".Body", string(hqr.Body))
+	SetVariable(ctx, vname+".StatusCode", fmt.Sprintf("%d", hqr.StatusCode))
+	SetVariable(ctx, vname+".Error", hqr.ErrorMessage)
+	for hname := range hqr.Headers {
+		SetVariable(ctx, vname+".Header."+hname, hqr.Headers.Get(hname))
+	}
+
+	delete(ctx.jqrvariables, vname)
+	if len(hqr.Body) > 0 {
+		sj, perr := smartjson.ParseJSON(hqr.Body)
+		if perr == nil {
+			ctx.jqrvariables[vname] = JsonHttpQuery{Success: hqr.Success, ErrorMessage: hqr.ErrorMessage, QueryUrl: spec.Url, SmartJSON: sj}
 		}
 	}
+
+	if hqr.Success {
+		ctx.variables["LastHttpCallSuccess"] = "true"
+	} else {
+		callFailed(ctx, "LastHttpCallSuccess", "Http call failed: "+strings.TrimSpace(hqr.ErrorMessage))
+	}
 }
 
 /* Handler of following commands:
@@ -795,7 +1522,7 @@ func Command_CallHttpStoreJson(ctx *RunContext, cmdpart string) {
 func Command_ShellyRelay(ctx *RunContext, cmdpart string) {
 	parts := strings.Split(ResolveVariables(*ctx, cmdpart), " ")
 	if len(parts) != 4 {
-		ctx.variables["LastShellyRelayCallSuccess"] = "false"
+		callFailed(ctx, "LastShellyRelayCallSuccess", "ShellyRelay call failed: "+cmdpart)
 		return
 	}
 
@@ -814,13 +1541,13 @@ func Command_ShellyRelay(ctx *RunContext, cmdpart string) {
 
 	addressParts := strings.Split(strings.TrimSpace(parts[1]), ":")
 	if len(addressParts) != 1 && len(addressParts) != 2 {
-		ctx.variables["LastShellyRelayCallSuccess"] = "false"
+		callFailed(ctx, "LastShellyRelayCallSuccess", "ShellyRelay call failed: "+cmdpart)
 		return
 	}
 
 	inDeviceId, err := strconv.Atoi(parts[3])
 	if err != nil {
-		ctx.variables["LastShellyRelayCallSuccess"] = "false"
+		callFailed(ctx, "LastShellyRelayCallSuccess", "ShellyRelay call failed: "+cmdpart)
 		return
 	}
 	devhw.inDeviceId = inDeviceId
@@ -833,12 +1560,17 @@ func Command_ShellyRelay(ctx *RunContext, cmdpart string) {
 		}
 	}
 
+	if ctx.options.dryRun != nil {
+		dryRunShellyRelay(ctx, parts)
+		return
+	}
+
 	deviceHandler := newShellyDevice()
 
 	if parts[2] == "readrelay" {
 		r := deviceHandler.QuerySwitch(&devhw, "program")
 		if !r.ok {
-			ctx.variables["LastShellyRelayCallSuccess"] = "false"
+			callFailed(ctx, "LastShellyRelayCallSuccess", "ShellyRelay call failed: "+cmdpart)
 			return
 		}
 		ctx.variables[parts[0]] = fmt.Sprintf("%t", r.state == 1)
@@ -856,7 +1588,7 @@ func Command_ShellyRelay(ctx *RunContext, cmdpart string) {
 		}
 		r := deviceHandler.SwitchTo(&devhw, value, "program")
 		if !r.ok {
-			ctx.variables["LastShellyRelayCallSuccess"] = "false"
+			callFailed(ctx, "LastShellyRelayCallSuccess", "ShellyRelay call failed: "+cmdpart)
 			return
 		}
 		ctx.variables["LastShellyRelayCallSuccess"] = "true"
@@ -865,7 +1597,7 @@ func Command_ShellyRelay(ctx *RunContext, cmdpart string) {
 	if parts[2] == "readcover" {
 		r := deviceHandler.QueryShader(&devhw, true, "program")
 		if !r.ok {
-			ctx.variables["LastShellyRelayCallSuccess"] = "false"
+			callFailed(ctx, "LastShellyRelayCallSuccess", "ShellyRelay call failed: "+cmdpart)
 			return
 		}
 		ctx.variables[parts[0]] = fmt.Sprintf("%d", int(r.position))
@@ -877,13 +1609,13 @@ func Command_ShellyRelay(ctx *RunContext, cmdpart string) {
 	if parts[2] == "setcover" {
 		r := deviceHandler.PerformThis(&devhw, parts[0], "program")
 		if !r.ok {
-			ctx.variables["LastShellyRelayCallSuccess"] = "false"
+			callFailed(ctx, "LastShellyRelayCallSuccess", "ShellyRelay call failed: "+cmdpart)
 			return
 		}
 		ctx.variables["LastShellyRelayCallSuccess"] = "true"
 		return
 	}
-	ctx.variables["LastShellyRelayCallSuccess"] = "false"
+	callFailed(ctx, "LastShellyRelayCallSuccess", "ShellyRelay call failed: "+cmdpart)
 }
 
 /* Handler of following commands:
@@ -893,28 +1625,33 @@ func Command_ShellyRelay(ctx *RunContext, cmdpart string) {
 func Command_ModbusTcp(ctx *RunContext, cmdpart string) {
 	parts := strings.Split(ResolveVariables(*ctx, cmdpart), " ")
 	if len(parts) != 5 {
-		ctx.variables["LastModbusTcpCallSuccess"] = "false"
+		callFailed(ctx, "LastModbusTcpCallSuccess", "ModbusTcp call failed: "+cmdpart)
 		return
 	}
 	addressParts := strings.Split(strings.TrimSpace(parts[1]), ":")
 	if len(addressParts) != 2 {
-		ctx.variables["LastModbusTcpCallSuccess"] = "false"
+		callFailed(ctx, "LastModbusTcpCallSuccess", "ModbusTcp call failed: "+cmdpart)
 		return
 	}
 	unitId, err := strconv.Atoi(parts[2])
 	if err != nil {
-		ctx.variables["LastModbusTcpCallSuccess"] = "false"
+		callFailed(ctx, "LastModbusTcpCallSuccess", "ModbusTcp call failed: "+cmdpart)
 		return
 	}
 	modbusAddress, err := strconv.Atoi(parts[4])
 	if err != nil {
-		ctx.variables["LastModbusTcpCallSuccess"] = "false"
+		callFailed(ctx, "LastModbusTcpCallSuccess", "ModbusTcp call failed: "+cmdpart)
+		return
+	}
+
+	if ctx.options.dryRun != nil {
+		dryRunModbusTcp(ctx, parts)
 		return
 	}
 
 	modbulsClient, err := Dial(addressParts[0], addressParts[1], byte(unitId), 5*time.Second)
 	if err != nil {
-		ctx.variables["LastModbusTcpCallSuccess"] = "false"
+		callFailed(ctx, "LastModbusTcpCallSuccess", "ModbusTcp call failed: "+cmdpart)
 		if DebugLevel > 0 {
 			fmt.Println("ModbusTCP Error - connecting to Modbus TCP server: ", err)
 		}
@@ -925,7 +1662,7 @@ func Command_ModbusTcp(ctx *RunContext, cmdpart string) {
 	if parts[3] == "readcoil" {
 		value, err := modbulsClient.ReadSingleCoil(uint16(modbusAddress))
 		if err != nil {
-			ctx.variables["LastModbusTcpCallSuccess"] = "false"
+			callFailed(ctx, "LastModbusTcpCallSuccess", "ModbusTcp call failed: "+cmdpart)
 			if DebugLevel > 0 {
 				fmt.Println("ModbusTCP Error - reading coil: ", err)
 			}
@@ -938,7 +1675,7 @@ func Command_ModbusTcp(ctx *RunContext, cmdpart string) {
 	if parts[3] == "readinput" {
 		value, err := modbulsClient.ReadInputRegister(uint16(modbusAddress))
 		if err != nil {
-			ctx.variables["LastModbusTcpCallSuccess"] = "false"
+			callFailed(ctx, "LastModbusTcpCallSuccess", "ModbusTcp call failed: "+cmdpart)
 			if DebugLevel > 0 {
 				fmt.Println("ModbusTCP Error - reading input register: ", err)
 			}
@@ -956,7 +1693,7 @@ func Command_ModbusTcp(ctx *RunContext, cmdpart string) {
 		}
 		err := modbulsClient.WriteSingleCoil(uint16(modbusAddress), value)
 		if err != nil {
-			ctx.variables["LastModbusTcpCallSuccess"] = "false"
+			callFailed(ctx, "LastModbusTcpCallSuccess", "ModbusTcp call failed: "+cmdpart)
 			if DebugLevel > 0 {
 				fmt.Println("ModbusTCP Error - writing coil: ", err)
 			}
@@ -965,11 +1702,11 @@ func Command_ModbusTcp(ctx *RunContext, cmdpart string) {
 		ctx.variables["LastModbusTcpCallSuccess"] = "true"
 		return
 	}
-	ctx.variables["LastModbusTcpCallSuccess"] = "false"
+	callFailed(ctx, "LastModbusTcpCallSuccess", "ModbusTcp call failed: "+cmdpart)
 }
 
 func AddBaseVariables(ctx *RunContext) {
-	now := time.Now()
+	now := Now()
 	ctx.variables["Time.Hour"] = fmt.Sprintf("%02d", now.Hour())
 	ctx.variables["Time.Minute"] = fmt.Sprintf("%02d", now.Minute())
 	ctx.variables["Time.TimeHM"] = fmt.Sprintf("%02d:%02d", now.Hour(), now.Minute())
@@ -981,6 +1718,13 @@ func AddBaseVariables(ctx *RunContext) {
 	ctx.variables["Time.Day"] = fmt.Sprintf("%d", now.Day())
 	ctx.variables["Time.Year"] = fmt.Sprintf("%d", now.Year())
 	ctx.variables["Time.YearDay"] = fmt.Sprintf("%d", now.YearDay())
+	AddSunVariables(ctx, now)
+	if len(ScheduleProfiles) > 0 {
+		ctx.variables["Schedule.ActiveProfile"], _ = ScheduleProfileOn(now)
+	}
+	if len(PresencePanels) > 0 {
+		ctx.variables["PresenceSimulation.Active"] = TrueFalseTextFromBool(PresenceSimulationActive())
+	}
 }
 
 func Command_LoadVariablesFromPanelId(ctx *RunContext, cmdpart string) {
@@ -1010,12 +1754,111 @@ func Command_LoadVariablesFromPanelIdWithPrefix(ctx *RunContext, cmdpart string)
 	}
 }
 
+// Calls an action of a panel. The scheduler actions (on, off, open, close, start, stop, run, <temperature>) are
+// tried first, then the actions of the panel buttons. The updated panels are added to the related panels.
+func Command_Panel(ctx *RunContext, cmdpart string, relatedPanels *[]string) {
+	parts := strings.Fields(ResolveVariables(*ctx, cmdpart))
+	if len(parts) < 2 || len(parts) > 3 {
+		RaiseError(ctx, "Wrong parameters of Panel command: "+cmdpart)
+		return
+	}
+	panel := GetPanelById(parts[0])
+	if panel == nil {
+		RaiseError(ctx, "Unknown panel id: "+parts[0])
+		return
+	}
+
+	if action, isAction := panel.(*PanelAction); isAction && parts[1] == "run" && !action.RunInBackground {
+		// Runs as a nested program, so the nesting limit and the dry run apply to it
+		updatedIds := action.runCommandsNested(ctx)
+		*relatedPanels = append(*relatedPanels, "Updated "+strings.Join(updatedIds, " "))
+		return
+	}
+
+	if dryRunSkip(ctx, "Panel "+strings.Join(parts, " ")) {
+		return
+	}
+
+	param := ""
+	if len(parts) == 3 {
+		param = parts[2]
+	}
+	_, updatedIds, err := RunPanelAction(panel, parts[1], param, len(parts) == 3)
+	if err != nil {
+		RaiseError(ctx, "Panel "+parts[0]+" "+parts[1]+" failed: "+err.Error())
+	}
+	if len(updatedIds) > 0 {
+		*relatedPanels = append(*relatedPanels, "Updated "+strings.Join(updatedIds, " "))
+	}
+}
+
+// Runs an action of the panel. The schedulable actions of the panel are run as the scheduler does, the parameter
+// is checked and appended to the action name (e.g. tts/21.5). The other actions are the actions of the panel buttons,
+// the parameter is passed as the required parameter of the button action or appended to the action name.
+// Returns the result of the action ("ok" on success), the updated panel ids and the error of the unknown or failed action.
+func RunPanelAction(panel PanelInterface, actionName string, param string, hasParam bool) (string, []string, error) {
+	if action, found := findSchedulableAction(panel.SchedulableActions(), actionName); found {
+		if hasParam {
+			if err := checkSchedulableActionParam(action, param); err != nil {
+				return "error", []string{}, err
+			}
+			actionName += "/" + param
+		} else if action.Param != ParamNone {
+			return "error", []string{}, fmt.Errorf("the action %s requires a parameter", actionName)
+		}
+		updatedIds, err := panel.DoActionFromScheduler(actionName)
+		if err != nil {
+			return "error", updatedIds, err
+		}
+		return "ok", updatedIds, nil
+	}
+
+	parameters := map[string]string{}
+	if hasParam {
+		required := panel.RequiredActionParameters(actionName)
+		if len(required) > 0 {
+			parameters[required[0]] = param
+		} else {
+			actionName += "/" + param
+		}
+	}
+
+	if !hasParam {
+		updatedIds, err := panel.DoActionFromScheduler(actionName)
+		if err != errUnknownScheduledAction {
+			if err != nil {
+				return "error", updatedIds, err
+			}
+			return "ok", updatedIds, nil
+		}
+	}
+	if !panel.IsActionIdMatch("b-" + panel.IdStr() + "-" + actionName) {
+		return "error", []string{}, fmt.Errorf("unknown action: %s", actionName)
+	}
+	if devicePanel, ok := panel.(PanelDeviceActionInterface); ok {
+		result, updatedIds, _, err := devicePanel.DoDeviceAction(actionName, parameters)
+		if err != nil {
+			return "error", updatedIds, err
+		}
+		return result, updatedIds, nil
+	}
+	result, updatedIds, _ := panel.DoAction(actionName, parameters)
+	if result != "ok" {
+		return result, updatedIds, fmt.Errorf("the action is failed: %s", result)
+	}
+	return result, updatedIds, nil
+}
+
 func getUpdatedIdsFromRelatedPanels(relatedPanels []string) []string {
 	var updatedIds []string = []string{}
 
 	for i := 0; i < len(relatedPanels); i++ {
 		rpstr := strings.TrimSpace(relatedPanels[i])
 		parts := strings.Split(rpstr, " ")
+		if parts[0] == "Updated" {
+			updatedIds = append(updatedIds, parts[1:]...)
+			continue
+		}
 		if len(parts) == 3 {
 			var pt PanelTypes = Unknown
 			if parts[0] == "Switch" {
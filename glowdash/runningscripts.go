@@ -0,0 +1,117 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyper-prog/smartyaml"
+)
+
+type PageRunningScripts struct {
+	PageBase
+}
+
+func NewPageRunningScripts() *PageRunningScripts {
+	return &PageRunningScripts{
+		PageBase{
+			idStr:      "",
+			pageType:   RunningScripts,
+			title:      "",
+			deviceType: "",
+			index:      0,
+		},
+	}
+}
+
+func (p *PageRunningScripts) LoadCustomConfig(sy smartyaml.SmartYAML, indexInConfig int) {
+	if p.title == "" {
+		p.title = T("Running programs")
+	}
+}
+
+func (p PageRunningScripts) PageHtml(withContainer bool, r *http.Request) string {
+	html := "<div class=\"schedule-edit-page\">"
+	html += "<h3>" + p.title + "</h3>"
+	html += "<div id=\"runningscripts-list\">"
+	html += htmlRunningScriptsTable()
+	html += "</div>"
+	html += "<button id=\"act-scripts-refresh\" class=\"jsaction scheduleedit-ctrl-button\">" + T("Refresh") + "</button>"
+	html += "</div>"
+
+	if withContainer {
+		return fmt.Sprintf("<div id=\"pc-%s\" class=\"fullpage-content\" tabindex=\"-1\">", p.IdStr()) +
+			html + "</div>"
+	}
+
+	return html
+}
+
+func htmlRunningScriptsTable() string {
+	list := listRunningScripts()
+	if len(list) == 0 {
+		return "<p class=\"whitetext\">" + T("There is no running program.") + "</p>"
+	}
+
+	h := "<table class=\"stattable\">"
+	h += "<tr><th>" + T("Num") +
+		"</th><th>" + T("Program") +
+		"</th><th>" + T("Started") +
+		"</th><th>" + T("Elapsed") +
+		"</th><th>" + T("Commands") +
+		"</th><th></th></tr>"
+	for i, rs := range list {
+		name := rs.Name
+		if name == "" {
+			name = "-"
+		}
+		h += "<tr class=\"" + IfTrue(i%2 == 0, "normcolor") + IfTrue(i%2 == 1, "altcolor") + "\">"
+		h += "<td>" + fmt.Sprintf("%d", rs.Id) + "</td>"
+		h += "<td>" + html.EscapeString(name) + "</td>"
+		h += "<td>" + rs.Started.In(TimeLocation).Format("2006-01-02 15:04:05") + "</td>"
+		h += "<td>" + rs.Elapsed.Round(time.Second).String() + "</td>"
+		h += "<td>" + fmt.Sprintf("%d", rs.Instructions) + "</td>"
+		h += "<td><button class=\"jsaction scheduleedit-ctrl-button\" id=\"act-script-kill-" + fmt.Sprintf("%d", rs.Id) + "\">" +
+			T("Kill") + "</button></td>"
+		h += "</tr>"
+	}
+	h += "</table>"
+	return h
+}
+
+func (p PageRunningScripts) IsActionIdMatch(aId string) bool {
+	if aId == "act-scripts-refresh" {
+		return true
+	}
+	if strings.HasPrefix(aId, "act-script-kill-") {
+		return true
+	}
+	return false
+}
+
+func (p PageRunningScripts) HandleActionEvent(res *ActionResponse, actionName string, parameters map[string]string) {
+	if actionName == "act-scripts-refresh" {
+		res.addCommandArg0("refreshpage")
+		res.setResultString("ok")
+	}
+	if strings.HasPrefix(actionName, "act-script-kill-") {
+		id, err := strconv.Atoi(actionName[16:])
+		if err == nil {
+			killRunningScript(id)
+			// Give a moment to the killed program to finish
+			time.Sleep(100 * time.Millisecond)
+			res.addCommandArg0("refreshpage")
+		}
+		res.setResultString("ok")
+	}
+}
@@ -8,11 +8,13 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"html"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/hyper-prog/smartyaml"
 )
@@ -39,15 +41,60 @@ func (p *PageScheduleEdit) LoadCustomConfig(sy smartyaml.SmartYAML, indexInConfi
 	}
 }
 
-func subActionCodeToDisplay(code string) string {
-	if code == "" {
-		return T("Nothing")
+// The options of the scheduled actions of the panel
+func htmlScheduleSubActionOptions(panel PanelInterface, selected string) string {
+	h := ""
+	for _, option := range scheduleActionOptions(panel.SchedulableActions()) {
+		h += "<option value=\"" + html.EscapeString(option.Value) + "\" " + IfTrue(scheduleActionOptionMatches(option, selected), "selected") + ">" +
+			html.EscapeString(option.Text) + "</option>"
 	}
-	dtext, found := subActionDisplayText[code]
-	if found {
-		return T(dtext)
+	return h
+}
+
+// The options of all panels for the action selector, the action subselects are filled from it on the client side
+func htmlScheduleActionsData() string {
+	actions := map[string][]ScheduleActionOption{}
+	for i := 0; i < len(Panels); i++ {
+		if options := scheduleActionOptions(Panels[i].SchedulableActions()); len(options) > 0 {
+			actions[Panels[i].IdStr()] = options
+		}
+	}
+	data, _ := json.Marshal(actions)
+	return html.EscapeString(string(data))
+}
+
+// The usual end action of the action (switch on -> off), it is offered in the editor
+func scheduleDefaultEndAction(action string) string {
+	if action == "" || action == "on" {
+		return "off"
+	}
+	if prefix, found := strings.CutSuffix(action, "/on"); found {
+		return prefix + "/off"
+	}
+	if action == "open" {
+		return "close"
+	}
+	if action == "start" {
+		return "stop"
+	}
+	return action
+}
+
+// The value of the weekly (empty) kind in the form
+func scheduleKindFormValue(kind string) string {
+	if kind == "" {
+		return "weekly"
 	}
-	return code + " C"
+	return kind
+}
+
+func scheduleKindFromFormValue(value string) string {
+	for _, kind := range ScheduleKinds {
+		if kind != "" && kind == value {
+			return kind
+		}
+	}
+	return ""
 }
 
 func ProcessScheduleForm(r *http.Request) string {
@@ -84,7 +131,7 @@ func ProcessScheduleForm(r *http.Request) string {
 
 	formname := r.Form.Get("sdlname")
 	if len(formname) < 1 {
-		t := time.Now()
+		t := Now()
 		formname = T("Unnamed schedule - {{time}}", map[string]any{"time": t.Format("15:04:05")})
 	}
 	s.name = formname
@@ -115,6 +162,69 @@ func ProcessScheduleForm(r *http.Request) string {
 		s.min = min
 	}
 
+	s.kind = scheduleKindFromFormValue(r.Form.Get("sdlkind"))
+	interval, erri := strconv.Atoi(r.Form.Get("sdlinterval"))
+	if s.kind == "interval" && erri == nil {
+		s.interval = interval
+	}
+	if s.kind == "cron" {
+		s.cron = strings.Join(strings.Fields(r.Form.Get("sdlcron")), " ")
+	}
+	if s.kind == "date" {
+		s.dates = strings.ReplaceAll(r.Form.Get("sdldates"), " ", "")
+	}
+	catchup, errc := strconv.Atoi(r.Form.Get("sdlcatchup"))
+	if errc == nil && catchup > 0 && !s.oneshot {
+		s.catchup = catchup
+		if s.catchup > scheduleMaxCatchup {
+			s.catchup = scheduleMaxCatchup
+		}
+	}
+	jitter, errj := strconv.Atoi(r.Form.Get("sdljitter"))
+	if errj == nil && jitter > 0 && !s.oneshot {
+		s.jitter = jitter
+		if s.jitter > scheduleMaxJitter {
+			s.jitter = scheduleMaxJitter
+		}
+	}
+	retries, errr := strconv.Atoi(r.Form.Get("sdlretries"))
+	if errr == nil && retries > 0 && !s.oneshot {
+		s.retries = retries
+		if s.retries > scheduleMaxRetries {
+			s.retries = scheduleMaxRetries
+		}
+		s.retryDelay = 5
+		retryDelay, errd := strconv.Atoi(r.Form.Get("sdlretrydelay"))
+		if errd == nil && retryDelay > 0 && retryDelay <= 1440 {
+			s.retryDelay = retryDelay
+		}
+	}
+	duration, errd := strconv.Atoi(r.Form.Get("sdlduration"))
+	if errd == nil && duration > 0 && r.Form.Get("endaction") != "" {
+		s.duration = duration
+		if s.duration > scheduleMaxDuration {
+			s.duration = scheduleMaxDuration
+		}
+		s.endAction = r.Form.Get("endaction")
+	}
+	if mode == "e" {
+		s.lastrun = getScheduleByIndex(index).lastrun
+	}
+
+	if message := checkScheduleKindData(s); message != "" {
+		GlowdashConsole.Write(T("ERROR: Schedule \"{{name}}\" is not saved: {{message}}",
+			map[string]any{"name": html.EscapeString(s.name), "message": html.EscapeString(message)}))
+		return mode
+	}
+
+	if isSunEvent(r.Form.Get("timeref")) {
+		s.timeRef = r.Form.Get("timeref")
+		offset, erro := strconv.Atoi(r.Form.Get("timeoffset"))
+		if erro == nil {
+			s.timeOffset = offset
+		}
+	}
+
 	s.dayMon = false
 	if r.Form.Get("mon") == "on" {
 		s.dayMon = true
@@ -150,6 +260,8 @@ func ProcessScheduleForm(r *http.Request) string {
 		s.actionType = msel_parts[0]
 		s.actionId = msel_parts[1]
 		s.actionParam = r.Form.Get("subaction")
+		s.condition = strings.TrimSpace(r.Form.Get("sdlcond"))
+		s.profiles = strings.Join(r.Form["sdlprofile"], ",")
 	} else {
 		return mode
 	}
@@ -163,15 +275,54 @@ func ProcessScheduleForm(r *http.Request) string {
 	return mode
 }
 
+// Imports the uploaded schedule set, returns "i" if the import form was submitted
+func ProcessScheduleImport(r *http.Request) string {
+	if r.Method != "POST" || !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return ""
+	}
+	if err := r.ParseMultipartForm(1 << 20); err != nil || r.FormValue("sdlimport") == "" {
+		return ""
+	}
+	file, _, err := r.FormFile("sdlimportfile")
+	if err != nil {
+		GlowdashConsole.Write(T("ERROR: Schedule import failed: {{message}}", map[string]any{"message": T("Missing file")}))
+		return "i"
+	}
+	defer file.Close()
+	content, err := io.ReadAll(io.LimitReader(file, 1<<20))
+	count := 0
+	if err == nil {
+		count, err = importSchedules(content, r.FormValue("sdlimportmode") == "replace")
+	}
+	if err != nil {
+		GlowdashConsole.Write(T("ERROR: Schedule import failed: {{message}}", map[string]any{"message": html.EscapeString(err.Error())}))
+		return "i"
+	}
+	GlowdashConsole.Write(T("{{count}} schedules imported", map[string]any{"count": count}))
+	return "i"
+}
+
+func htmlScheduleImportExport() string {
+	h := "<div class=\"schedule-import-export\">"
+	h += "<a href=\"/schedules/export\" class=\"scheduleedit-ctrl-button\" download>" + T("Export schedules") + "</a>"
+	h += "<form method=\"post\" enctype=\"multipart/form-data\">"
+	h += "<input type=\"file\" name=\"sdlimportfile\" accept=\".json,application/json\"/>"
+	h += "<select name=\"sdlimportmode\">"
+	h += "<option value=\"merge\">" + T("Add and update") + "</option>"
+	h += "<option value=\"replace\">" + T("Replace all") + "</option>"
+	h += "</select>"
+	h += "<input type=\"submit\" name=\"sdlimport\" value=\"" + T("Import schedules") + "\" class=\"schedule-submit-button\" />"
+	h += "</form>"
+	h += "</div>"
+	return h
+}
+
 func (p PageScheduleEdit) PageHtml(withContainer bool, r *http.Request) string {
 	html := ""
-	html += "<script>window.TRANSLATIONS = {"
-	for _, text := range requiredClientTranslationTexts {
-		html += fmt.Sprintf("\"%s\": \"%s\",", text, T(text))
+	formmode := ProcessScheduleImport(r)
+	if formmode == "" {
+		formmode = ProcessScheduleForm(r)
 	}
-	html += "};</script>"
-
-	formmode := ProcessScheduleForm(r)
 	html += "<div class=\"schedule-edit-page\">"
 	html += "<h3>" + p.title + "</h3>"
 	html += "<div id=\"scheduleedit-main-list-container\" class=\"schedule-list\">"
@@ -190,6 +341,7 @@ func (p PageScheduleEdit) PageHtml(withContainer bool, r *http.Request) string {
 
 	html += "<button id=\"schedule-edit-add\" class=\"jsaction scheduleedit-ctrl-button\"><i class=\"fa fa-add3\"></i></button>"
 	html += "<button id=\"schedule-edit-addoneshot\" class=\"jsaction scheduleedit-ctrl-button\"><i class=\"fa fa-gun\"></i></button>"
+	html += htmlScheduleImportExport()
 	if formmode != "" {
 		html += "<script>setTimeout(\"window.location = '/page/schedpage';\",200);</script>"
 	}
@@ -215,9 +367,11 @@ func htmlStaticScheduleBlock(index int, cS int, s Schedule) string {
 		}
 	}
 
-	html += "<div class=\"schedule-item-time\">" + fmt.Sprintf("%02d:%02d", s.hour, s.min) + "</div>"
+	html += "<div class=\"schedule-item-time" + IfTrue(s.timeRef != "" || s.kind != "", " schedule-item-time-text") + "\">" + scheduleTimeText(s) + "</div>"
 
-	html += htmlScheduleDays(s, "short", true)
+	if s.kind == "" || s.kind == "interval" {
+		html += htmlScheduleDays(s, "short", true)
+	}
 
 	title := "-"
 	refPanel := GetPanelById(s.actionId)
@@ -227,9 +381,26 @@ func htmlStaticScheduleBlock(index int, cS int, s Schedule) string {
 	html += "<div class=\"schedule-item-act\">"
 	html += "<span class=\"schedule-item-act-id\">" + title + "</span>"
 	html += "<span class=\"schedule-item-act-sep\"><i class=\"fa fa-rightarrow\"></i></span>"
-	html += "<span class=\"schedule-item-act-param\">" + subActionCodeToDisplay(s.actionParam) + "</span>"
+	html += "<span class=\"schedule-item-act-param\">" + scheduleActionDisplayText(s.actionId, s.actionParam) + "</span>"
 	html += "<br/>"
-	html += "<span class=\"schedule-item-lastrun\">" + T("Last run on:") + " " + s.lastrun + "</span>"
+	html += "<span class=\"schedule-item-lastrun\">" + T("Last run on:") + " " + scheduleLastrunText(s) + "</span>"
+	html += htmlScheduleLastResult(s)
+	if s.duration > 0 {
+		html += "<br/>"
+		html += "<span class=\"schedule-item-lastrun\">" + T("At the end:") + " " + scheduleActionDisplayText(s.actionId, s.endAction)
+		if due, pending := scheduleEndPending(s.name); pending {
+			html += " (" + T("running until {{time}}", map[string]any{"time": due.In(TimeLocation).Format("15:04")}) + ")"
+		}
+		html += "</span>"
+	}
+	if s.profiles != "" {
+		html += "<br/>"
+		html += "<span class=\"schedule-item-lastrun\">" + T("Profiles:") + " " + strings.ReplaceAll(s.profiles, ",", ", ") + "</span>"
+	}
+	if s.condition != "" {
+		html += "<br/>"
+		html += "<span class=\"schedule-item-lastrun\">" + T("Only if:") + " " + strings.ReplaceAll(s.condition, "<", "&lt;") + "</span>"
+	}
 	html += "</div>" //.schedule-item-act
 
 	html += "<div class=\"schedule-item-func\"><table>"
@@ -269,7 +440,7 @@ func htmlDeleteConfirmation(index int, s Schedule) string {
 func htmlScheduleEditor(new bool, oneshotIfNew bool, s Schedule) string {
 	html := "<div class=\"schedule-item\"><form method=\"post\" enctype=\"application/x-www-form-urlencoded\">"
 
-	current_time := time.Now()
+	current_time := Now()
 	if new {
 		if oneshotIfNew {
 			html += "<span style=\"font-weight: strong; font-size: larger; padding: 5px;\">" + T("New one shot schedule") + "</span>"
@@ -339,7 +510,85 @@ func htmlScheduleEditor(new bool, oneshotIfNew bool, s Schedule) string {
 	html += "</div>"
 	html += "</div>"
 
-	html += "<div class=\"schedule-data-block\">"
+	if (new && oneshotIfNew) || (!new && s.oneshot) {
+		html += "<input type=\"hidden\" name=\"sdlkind\" value=\"weekly\" />"
+	} else {
+		html += "<div class=\"schedule-data-block\">"
+		html += "<div class=\"schedule-data-item-desc\">" + T("Schedule type") + "</div>"
+		html += "<div class=\"schedule-data-item-value\">"
+		html += "<select name=\"sdlkind\" class=\"schedule-kind-selector\">"
+		for _, kind := range ScheduleKinds {
+			html += "<option value=\"" + scheduleKindFormValue(kind) + "\" " + IfTrue(s.kind == kind, "selected") + ">" +
+				T(scheduleKindDisplayText[kind]) + "</option>"
+		}
+		html += "</select>"
+		html += "</div>"
+		html += "</div>"
+
+		interval := s.interval
+		if interval < 1 {
+			interval = 15
+		}
+		html += "<div class=\"schedule-data-block\" data-schedkinds=\"interval\">"
+		html += "<div class=\"schedule-data-item-desc\">" + T("Repeat every (minutes)") + "</div>"
+		html += "<div class=\"schedule-data-item-value\">"
+		html += "<input type=\"number\" name=\"sdlinterval\" class=\"schedule-offset-input\" min=\"1\" max=\"1440\" value=\"" +
+			fmt.Sprintf("%d", interval) + "\"/><br/>"
+		html += T("From the running time until midnight")
+		html += "</div>"
+		html += "</div>"
+
+		html += "<div class=\"schedule-data-block\" data-schedkinds=\"cron\">"
+		html += "<div class=\"schedule-data-item-desc\">" + T("Cron expression") + "</div>"
+		html += "<div class=\"schedule-data-item-value\">"
+		html += "<input type=\"text\" name=\"sdlcron\" placeholder=\"*/15 6-22 * * 1-5\" value=\"" + s.cron + "\"/><br/>"
+		html += T("minute hour day month weekday")
+		html += "</div>"
+		html += "</div>"
+
+		html += "<div class=\"schedule-data-block\" data-schedkinds=\"date\">"
+		html += "<div class=\"schedule-data-item-desc\">" + T("Dates") + "</div>"
+		html += "<div class=\"schedule-data-item-value\">"
+		html += "<input type=\"text\" name=\"sdldates\" placeholder=\"2026-12-24, 12-31\" value=\"" + s.dates + "\"/><br/>"
+		html += T("YYYY-MM-DD or MM-DD (every year), comma separated")
+		html += "</div>"
+		html += "</div>"
+
+		html += "<div class=\"schedule-data-block\">"
+		html += "<div class=\"schedule-data-item-desc\">" + T("Random shift (± minutes)") + "</div>"
+		html += "<div class=\"schedule-data-item-value\">"
+		html += "<input type=\"number\" name=\"sdljitter\" class=\"schedule-offset-input\" min=\"0\" max=\"" +
+			fmt.Sprintf("%d", scheduleMaxJitter) + "\" value=\"" + fmt.Sprintf("%d", s.jitter) + "\"/><br/>"
+		html += T("The running time is shifted randomly every day (0: exact time)")
+		html += "</div>"
+		html += "</div>"
+
+		retryDelay := s.retryDelay
+		if retryDelay < 1 {
+			retryDelay = 5
+		}
+		html += "<div class=\"schedule-data-block\">"
+		html += "<div class=\"schedule-data-item-desc\">" + T("Retry on failure") + "</div>"
+		html += "<div class=\"schedule-data-item-value\">"
+		html += T("Retries") + " <input type=\"number\" name=\"sdlretries\" class=\"schedule-offset-input\" min=\"0\" max=\"" +
+			fmt.Sprintf("%d", scheduleMaxRetries) + "\" value=\"" + fmt.Sprintf("%d", s.retries) + "\"/> "
+		html += T("Delay (minutes)") + " <input type=\"number\" name=\"sdlretrydelay\" class=\"schedule-offset-input\" min=\"1\" max=\"1440\" value=\"" +
+			fmt.Sprintf("%d", retryDelay) + "\"/><br/>"
+		html += T("The failed action is retried after the delay (0 retries: disabled)")
+		html += "</div>"
+		html += "</div>"
+
+		html += "<div class=\"schedule-data-block\">"
+		html += "<div class=\"schedule-data-item-desc\">" + T("Catch-up window (minutes)") + "</div>"
+		html += "<div class=\"schedule-data-item-value\">"
+		html += "<input type=\"number\" name=\"sdlcatchup\" class=\"schedule-offset-input\" min=\"0\" max=\"" +
+			fmt.Sprintf("%d", scheduleMaxCatchup) + "\" value=\"" + fmt.Sprintf("%d", s.catchup) + "\"/><br/>"
+		html += T("A missed run is fired late once within this time (0: disabled)")
+		html += "</div>"
+		html += "</div>"
+	}
+
+	html += "<div class=\"schedule-data-block\" data-schedkinds=\"weekly interval date\">"
 	html += "<div class=\"schedule-data-item-desc\">" + T("Running time") + "</div>"
 	html += "<div class=\"schedule-data-item-value\">"
 	if new {
@@ -347,10 +596,22 @@ func htmlScheduleEditor(new bool, oneshotIfNew bool, s Schedule) string {
 	} else {
 		html += htmlClockPicker("editsch", s.hour, s.min, true, "", "none")
 	}
+	if LocationSet || s.timeRef != "" {
+		html += "<br/>"
+		html += "<select name=\"timeref\">"
+		html += "<option value=\"\" " + IfTrue(s.timeRef == "", "selected") + ">" + T("Fixed time") + "</option>"
+		for _, event := range SunEvents {
+			html += "<option value=\"" + event + "\" " + IfTrue(s.timeRef == event, "selected") + ">" + T(sunEventDisplayText[event]) + "</option>"
+		}
+		html += "</select>"
+		html += "<br/>" + T("Offset (minutes)") + " "
+		html += "<input type=\"number\" name=\"timeoffset\" class=\"schedule-offset-input\" min=\"-720\" max=\"720\" value=\"" +
+			fmt.Sprintf("%d", s.timeOffset) + "\"/>"
+	}
 	html += "</div>"
 	html += "</div>"
 
-	html += "<div class=\"schedule-data-block\">"
+	html += "<div class=\"schedule-data-block\" data-schedkinds=\"weekly interval\">"
 	html += "<div class=\"schedule-data-item-desc\">" + T("Running days") + "</div>"
 	html += "<div class=\"schedule-data-item-value\">"
 	html += "" +
@@ -367,12 +628,13 @@ func htmlScheduleEditor(new bool, oneshotIfNew bool, s Schedule) string {
 	html += "<div class=\"schedule-data-block\">"
 	html += "<div class=\"schedule-data-item-desc\">" + T("Action on Time") + "</div>"
 	html += "<div class=\"schedule-data-item-value\">"
-	html += "<select name=\"action\" class=\"schedule-action-selector\" data-actionsubid=\"sch-sub-sel-one\">"
+	html += "<select name=\"action\" class=\"schedule-action-selector\" data-actionsubid=\"sch-sub-sel-one\" data-actionendid=\"sch-sub-sel-end\" data-actions=\"" + htmlScheduleActionsData() + "\">"
 	panelcnt := len(Panels)
 	subselOpts := ""
+	endselOpts := ""
 	showindex := 0
 	for i := 0; i < panelcnt; i++ {
-		if strings.HasPrefix(Panels[i].IdStr(), "autogenId") {
+		if strings.HasPrefix(Panels[i].IdStr(), "autogenId") || len(Panels[i].SchedulableActions()) == 0 {
 			continue
 		}
 
@@ -384,45 +646,16 @@ func htmlScheduleEditor(new bool, oneshotIfNew bool, s Schedule) string {
 			current = true
 		}
 
-		if Panels[i].PanelType() == Switch {
-			html += "<option value=\"switch:" + Panels[i].IdStr() + "\" " + selectedText + ">" + Panels[i].EventTitle() + "</option>"
-
-			if current {
-				subselOpts += "<option value=\"on\" " + IfTrue(s.actionParam == "on", "selected") + ">" + T("Switch On") + "</option>"
-				subselOpts += "<option value=\"off\" " + IfTrue(s.actionParam == "off", "selected") + ">" + T("Switch Off") + "</option>"
-			}
-		}
-		if Panels[i].PanelType() == Shading {
-			html += "<option value=\"shading:" + Panels[i].IdStr() + "\" " + selectedText + ">" + Panels[i].EventTitle() + "</option>"
-
-			if current {
-				subselOpts += "<option value=\"open\" " + IfTrue(s.actionParam == "open", "selected") + ">" + T("Open") + "</option>"
-				subselOpts += "<option value=\"close\" " + IfTrue(s.actionParam == "close", "selected") + ">" + T("Close") + "</option>"
-			}
-		}
-		if Panels[i].PanelType() == Action {
-			html += "<option value=\"action:" + Panels[i].IdStr() + "\" " + selectedText + ">" + Panels[i].EventTitle() + "</option>"
-			if current {
-				subselOpts += "<option value=\"run\" " + IfTrue(s.actionParam == "run", "selected") + ">" + T("Run") + "</option>"
-			}
-		}
-		if Panels[i].PanelType() == Script {
-			html += "<option value=\"script:" + Panels[i].IdStr() + "\" " + selectedText + ">" + Panels[i].EventTitle() + "</option>"
-
-			if current {
-				subselOpts += "<option value=\"start\" " + IfTrue(s.actionParam == "start", "selected") + ">" + T("Start") + "</option>"
-				subselOpts += "<option value=\"stop\" " + IfTrue(s.actionParam == "stop", "selected") + ">" + T("Stop") + "</option>"
-			}
-		}
-		if Panels[i].PanelType() == Thermostat {
-			html += "<option value=\"therm:" + Panels[i].IdStr() + "\" " + selectedText + ">" + Panels[i].EventTitle() + "</option>"
+		html += "<option value=\"" + scheduleActionTypeNames[Panels[i].PanelType()] + ":" + Panels[i].IdStr() + "\" " + selectedText + ">" +
+			Panels[i].EventTitle() + "</option>"
 
-			if current {
-				apf, _ := strconv.ParseFloat(s.actionParam, 8)
-				for t := 5.0; t <= 30; t += 0.5 {
-					subselOpts += "<option value=\"" + fmt.Sprintf("%.1f", t) + "\" " + IfTrue(t == apf, "selected") + ">" + fmt.Sprintf("%.1f", t) + "</option>"
-				}
+		if current {
+			subselOpts = htmlScheduleSubActionOptions(Panels[i], s.actionParam)
+			endAction := s.endAction
+			if endAction == "" {
+				endAction = scheduleDefaultEndAction(s.actionParam)
 			}
+			endselOpts = htmlScheduleSubActionOptions(Panels[i], endAction)
 		}
 
 		showindex++
@@ -437,6 +670,34 @@ func htmlScheduleEditor(new bool, oneshotIfNew bool, s Schedule) string {
 	html += "</div>"
 	html += "</div>"
 
+	html += "<div class=\"schedule-data-block\">"
+	html += "<div class=\"schedule-data-item-desc\">" + T("Duration (minutes)") + "</div>"
+	html += "<div class=\"schedule-data-item-value\">"
+	html += "<input type=\"number\" name=\"sdlduration\" class=\"schedule-offset-input\" min=\"0\" max=\"" +
+		fmt.Sprintf("%d", scheduleMaxDuration) + "\" value=\"" + fmt.Sprintf("%d", s.duration) + "\"/>"
+	html += "&nbsp;<i class=\"fa fa-rightarrow\"></i>&nbsp;"
+	html += "<select id=\"sch-sub-sel-end\" name=\"endaction\">"
+	html += endselOpts
+	html += "</select><br/>"
+	html += T("The end action runs after the duration (0: no end action)")
+	html += "</div>"
+	html += "</div>"
+
+	if len(ScheduleProfiles) > 0 {
+		html += "<div class=\"schedule-data-block\">"
+		html += "<div class=\"schedule-data-item-desc\">" + T("Profiles") + "</div>"
+		html += "<div class=\"schedule-data-item-value\">"
+		for _, profile := range ScheduleProfiles {
+			html += profile + "<input type=\"checkbox\" name=\"sdlprofile\" value=\"" + profile + "\" " +
+				IfTrue(scheduleHasProfile(s, profile), "checked") + "/> "
+		}
+		html += "<br/>" + T("Runs only if one of the checked profiles is active (runs always if none is checked)")
+		html += "</div>"
+		html += "</div>"
+	}
+
+	html += htmlScheduleConditionBlock(s)
+
 	html += "<div class=\"schedule-data-block\">"
 	html += "<div class=\"schedule-data-item-desc\">" + T("Operation") + "</div>"
 	html += "<div class=\"schedule-data-item-value\">"
@@ -456,6 +717,36 @@ func htmlScheduleEditor(new bool, oneshotIfNew bool, s Schedule) string {
 	return html
 }
 
+// The result of the last execution and the link of the filtered history page
+func htmlScheduleLastResult(s Schedule) string {
+	h := ""
+	last := filterScheduleHistory(s.name, "", 0, 1)
+	if len(last) > 0 {
+		result := T(last[0].Result)
+		if last[0].Error != "" {
+			result += ": " + last[0].Error
+		}
+		h += "<br/>"
+		h += "<span class=\"schedule-item-lastrun" + IfTrue(last[0].Result == "failed", " schedule-item-failed") + "\">" +
+			T("Last result:") + " " + html.EscapeString(result) + "</span>"
+	}
+	if link := scheduleHistoryPageLink(s.name); link != "" {
+		h += " <a class=\"schedule-item-lastrun\" href=\"" + html.EscapeString(link) + "\">" + T("History") + "</a>"
+	}
+	return h
+}
+
+func htmlScheduleConditionBlock(s Schedule) string {
+	h := "<div class=\"schedule-data-block\">"
+	h += "<div class=\"schedule-data-item-desc\">" + T("Condition") + "</div>"
+	h += "<div class=\"schedule-data-item-value\">"
+	h += "<input type=\"text\" name=\"sdlcond\" placeholder=\"{{state.vacation}} booleq false\" value=\"" + html.EscapeString(s.condition) + "\"/><br/>"
+	h += T("Runs only if true: an expression like at the If command, or program:name")
+	h += "</div>"
+	h += "</div>"
+	return h
+}
+
 func (p PageScheduleEdit) IsActionIdMatch(aId string) bool {
 	if aId == "schedule-edit-add" {
 		return true
@@ -0,0 +1,131 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"sync"
+	"time"
+)
+
+// The pending end action of a schedule with duration. It is stored in a file,
+// so the end action runs after a restart too (late, if the end time was missed).
+type ScheduleEnd struct {
+	Schedule string    `json:"schedule"`
+	PanelId  string    `json:"panel"`
+	Action   string    `json:"action"`
+	Due      time.Time `json:"due"`
+	CatchUp  bool      `json:"catchup,omitempty"`
+}
+
+// The maximum duration of a schedule (minutes)
+const scheduleMaxDuration int = 1440
+
+var scheduleEnds []ScheduleEnd = []ScheduleEnd{}
+var scheduleEndsMutex sync.Mutex
+
+// Registers the end action of the started schedule. A new start of the same schedule
+// replaces the pending end, so the duration is counted from the last start.
+func addScheduleEnd(s Schedule, started time.Time, catchUp bool) {
+	if s.duration < 1 || s.endAction == "" {
+		return
+	}
+	e := ScheduleEnd{Schedule: s.name, PanelId: s.actionId, Action: s.endAction,
+		Due: started.Truncate(time.Minute).Add(time.Duration(s.duration) * time.Minute), CatchUp: catchUp}
+	scheduleEndsMutex.Lock()
+	list := []ScheduleEnd{e}
+	for _, pending := range scheduleEnds {
+		if pending.Schedule != s.name {
+			list = append(list, pending)
+		}
+	}
+	scheduleEnds = list
+	scheduleEndsMutex.Unlock()
+	SaveScheduleEndsToFile()
+}
+
+// Returns the pending end time of the schedule
+func scheduleEndPending(name string) (time.Time, bool) {
+	scheduleEndsMutex.Lock()
+	defer scheduleEndsMutex.Unlock()
+	for _, e := range scheduleEnds {
+		if e.Schedule == name {
+			return e.Due, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Runs the due end actions. The end action runs also if the schedule is deleted or disabled meanwhile,
+// it has the retry policy of the schedule (if it still exists).
+func runDueScheduleEnds(now time.Time) {
+	due := []ScheduleEnd{}
+	scheduleEndsMutex.Lock()
+	waiting := []ScheduleEnd{}
+	for _, e := range scheduleEnds {
+		if e.Due.After(now) {
+			waiting = append(waiting, e)
+		} else {
+			due = append(due, e)
+		}
+	}
+	scheduleEnds = waiting
+	scheduleEndsMutex.Unlock()
+	if len(due) == 0 {
+		return
+	}
+	SaveScheduleEndsToFile()
+
+	for _, e := range due {
+		if now.Sub(e.Due) >= time.Minute {
+			GlowdashConsole.Write(T("End action of schedule \"{{name}}\" is run late, it was due at {{time}}",
+				map[string]any{"name": html.EscapeString(e.Schedule), "time": e.Due.In(TimeLocation).Format("2006-01-02 15:04")}))
+		}
+		scheduleMutex.Lock()
+		s := getScheduleByName(e.Schedule)
+		scheduleMutex.Unlock()
+		s.name = e.Schedule
+		s.actionId = e.PanelId
+		s.actionParam = e.Action
+		s.duration = 0
+		s.oneshot = true
+		runScheduleAction(s, 1, e.CatchUp)
+	}
+}
+
+func SaveScheduleEndsToFile() {
+	scheduleEndsMutex.Lock()
+	content, err := json.MarshalIndent(scheduleEnds, "", "  ")
+	scheduleEndsMutex.Unlock()
+	if err != nil {
+		fmt.Println("Cannot encode schedule ends")
+		return
+	}
+	err = writeFileAtomic(StateConfigDirectory+"/scheduleends.json", content)
+	if err != nil {
+		fmt.Printf("Cannot write scheduleends.json: %s\n", err)
+	}
+}
+
+func ReadScheduleEndsFromFile() {
+	content, err := os.ReadFile(StateConfigDirectory + "/scheduleends.json")
+	if err != nil {
+		return
+	}
+	ends := []ScheduleEnd{}
+	if err = json.Unmarshal(content, &ends); err != nil {
+		fmt.Printf("Cannot parse scheduleends.json: %s\n", err)
+		return
+	}
+	scheduleEndsMutex.Lock()
+	scheduleEnds = ends
+	scheduleEndsMutex.Unlock()
+}
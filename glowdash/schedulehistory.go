@@ -0,0 +1,146 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// One execution of a schedule. The result is ok, failed or skipped (the condition was false or failed).
+type ScheduleHistoryEntry struct {
+	Time       time.Time `json:"time"`
+	Schedule   string    `json:"schedule"`
+	PanelId    string    `json:"panel"`
+	Action     string    `json:"action"`
+	Result     string    `json:"result"`
+	Error      string    `json:"error,omitempty"`
+	UpdatedIds []string  `json:"updated,omitempty"`
+	Attempt    int       `json:"attempt"`
+	CatchUp    bool      `json:"catchup,omitempty"`
+}
+
+// The number of the kept history entries (all schedules together), the oldest are dropped
+var ScheduleHistoryMaxEntries int = 1000
+
+var scheduleHistory []ScheduleHistoryEntry = []ScheduleHistoryEntry{}
+var scheduleHistoryMutex sync.Mutex
+var scheduleHistorySaveMutex sync.Mutex
+var scheduleHistoryUnsaved bool = false
+
+func AddScheduleHistory(e ScheduleHistoryEntry) {
+	scheduleHistoryMutex.Lock()
+	defer scheduleHistoryMutex.Unlock()
+	scheduleHistory = append(scheduleHistory, e)
+	if len(scheduleHistory) > ScheduleHistoryMaxEntries {
+		scheduleHistory = scheduleHistory[len(scheduleHistory)-ScheduleHistoryMaxEntries:]
+	}
+	scheduleHistoryUnsaved = true
+}
+
+// Returns the matching entries, the newest first. The empty name or result matches all,
+// the days limits the age of the entries (0: no limit), the limit is the maximum count (0: no limit).
+func filterScheduleHistory(name string, result string, days int, limit int) []ScheduleHistoryEntry {
+	scheduleHistoryMutex.Lock()
+	defer scheduleHistoryMutex.Unlock()
+	from := time.Time{}
+	if days > 0 {
+		from = Now().AddDate(0, 0, -days)
+	}
+	list := []ScheduleHistoryEntry{}
+	for i := len(scheduleHistory) - 1; i >= 0; i-- {
+		e := scheduleHistory[i]
+		if (name != "" && e.Schedule != name) || (result != "" && e.Result != result) || e.Time.Before(from) {
+			continue
+		}
+		list = append(list, e)
+		if limit > 0 && len(list) >= limit {
+			break
+		}
+	}
+	return list
+}
+
+// The names of the schedules in the history (also the deleted ones)
+func scheduleHistoryNames() []string {
+	scheduleHistoryMutex.Lock()
+	defer scheduleHistoryMutex.Unlock()
+	names := []string{}
+	found := map[string]bool{}
+	for _, e := range scheduleHistory {
+		if !found[e.Schedule] {
+			found[e.Schedule] = true
+			names = append(names, e.Schedule)
+		}
+	}
+	return names
+}
+
+func clearScheduleHistory() {
+	scheduleHistoryMutex.Lock()
+	scheduleHistory = []ScheduleHistoryEntry{}
+	scheduleHistoryUnsaved = true
+	scheduleHistoryMutex.Unlock()
+}
+
+func SaveScheduleHistoryToFile() {
+	scheduleHistorySaveMutex.Lock()
+	defer scheduleHistorySaveMutex.Unlock()
+
+	scheduleHistoryMutex.Lock()
+	content, err := json.MarshalIndent(scheduleHistory, "", "  ")
+	scheduleHistoryUnsaved = false
+	scheduleHistoryMutex.Unlock()
+	if err != nil {
+		fmt.Println("Cannot encode schedule history")
+		return
+	}
+
+	if DebugLevel > 0 {
+		fmt.Println("Writing schedulehistory.json")
+	}
+
+	err = writeFileAtomic(StateConfigDirectory+"/schedulehistory.json", content)
+	if err != nil {
+		fmt.Printf("Cannot write schedulehistory.json: %s\n", err)
+		scheduleHistoryMutex.Lock()
+		scheduleHistoryUnsaved = true
+		scheduleHistoryMutex.Unlock()
+	}
+}
+
+func ReadScheduleHistoryFromFile() {
+	content, err := os.ReadFile(StateConfigDirectory + "/schedulehistory.json")
+	if err != nil {
+		return
+	}
+	history := []ScheduleHistoryEntry{}
+	if err = json.Unmarshal(content, &history); err != nil {
+		fmt.Printf("Cannot parse schedulehistory.json: %s\n", err)
+		return
+	}
+	if len(history) > ScheduleHistoryMaxEntries {
+		history = history[len(history)-ScheduleHistoryMaxEntries:]
+	}
+	scheduleHistoryMutex.Lock()
+	scheduleHistory = history
+	scheduleHistoryUnsaved = false
+	scheduleHistoryMutex.Unlock()
+}
+
+func SaveScheduleHistoryIfRequired() {
+	scheduleHistoryMutex.Lock()
+	unsaved := scheduleHistoryUnsaved
+	scheduleHistoryMutex.Unlock()
+	if unsaved {
+		SaveScheduleHistoryToFile()
+	}
+}
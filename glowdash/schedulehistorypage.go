@@ -0,0 +1,147 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/hyper-prog/smartyaml"
+)
+
+type PageScheduleHistory struct {
+	PageBase
+}
+
+func NewPageScheduleHistory() *PageScheduleHistory {
+	return &PageScheduleHistory{
+		PageBase{
+			idStr:      "",
+			pageType:   ScheduleHistory,
+			title:      "",
+			deviceType: "",
+			index:      0,
+		},
+	}
+}
+
+func (p *PageScheduleHistory) LoadCustomConfig(sy smartyaml.SmartYAML, indexInConfig int) {
+	if p.title == "" {
+		p.title = T("Schedule history")
+	}
+}
+
+var scheduleHistoryResults = []string{"ok", "failed", "skipped"}
+
+var scheduleHistoryDays_values = []int{1, 7, 30, 0}
+var scheduleHistoryDays_names = []string{"Last day", "Last week", "Last month", "All"}
+
+func (p PageScheduleHistory) PageHtml(withContainer bool, r *http.Request) string {
+	name := r.Form.Get("schedule")
+	result := r.Form.Get("result")
+	days, err := strconv.Atoi(r.Form.Get("days"))
+	if err != nil {
+		days = 7
+	}
+
+	h := "<div class=\"schedule-edit-page\">"
+	h += "<h3>" + p.title + "</h3>"
+	h += "<form method=\"get\">"
+	h += "<select name=\"schedule\">"
+	h += "<option value=\"\">" + T("All schedules") + "</option>"
+	for _, n := range scheduleHistoryNames() {
+		h += "<option value=\"" + html.EscapeString(n) + "\"" + IfTrue(n == name, " selected") + ">" + html.EscapeString(n) + "</option>"
+	}
+	h += "</select>"
+	h += "<select name=\"result\">"
+	h += "<option value=\"\">" + T("All results") + "</option>"
+	for _, res := range scheduleHistoryResults {
+		h += "<option value=\"" + res + "\"" + IfTrue(res == result, " selected") + ">" + T(res) + "</option>"
+	}
+	h += "</select>"
+	h += "<select name=\"days\">"
+	for i, n := range scheduleHistoryDays_names {
+		h += "<option value=\"" + fmt.Sprintf("%d", scheduleHistoryDays_values[i]) + "\"" +
+			IfTrue(scheduleHistoryDays_values[i] == days, " selected") + ">" + T(n) + "</option>"
+	}
+	h += "</select>"
+	h += "<input type=\"submit\" value=\"" + T("Show") + "\" class=\"schedule-submit-button\" />"
+	h += "</form>"
+	h += htmlScheduleHistoryTable(filterScheduleHistory(name, result, days, 0))
+	h += "<button id=\"act-schedhistory-clear\" class=\"jsaction scheduleedit-ctrl-button\">" + T("Clear") + "</button>"
+	h += "</div>"
+
+	if withContainer {
+		return fmt.Sprintf("<div id=\"pc-%s\" class=\"fullpage-content\" tabindex=\"-1\">", p.IdStr()) +
+			h + "</div>"
+	}
+
+	return h
+}
+
+func htmlScheduleHistoryTable(list []ScheduleHistoryEntry) string {
+	if len(list) == 0 {
+		return "<p class=\"whitetext\">" + T("There is no recorded schedule execution.") + "</p>"
+	}
+
+	h := "<table class=\"stattable\">"
+	h += "<tr><th>" + T("Time") +
+		"</th><th>" + T("Schedule") +
+		"</th><th>" + T("Action") +
+		"</th><th>" + T("Result") +
+		"</th><th>" + T("Attempt") +
+		"</th><th>" + T("Updated panels") +
+		"</th></tr>"
+	for i, e := range list {
+		title := e.PanelId
+		if panel := GetPanelById(e.PanelId); panel != nil {
+			title = panel.EventTitle()
+		}
+		result := T(e.Result)
+		if e.Error != "" {
+			result += ": " + e.Error
+		}
+		h += "<tr class=\"" + IfTrue(i%2 == 0, "normcolor") + IfTrue(i%2 == 1, "altcolor") + "\">"
+		h += "<td>" + e.Time.In(TimeLocation).Format("2006-01-02 15:04:05") + "</td>"
+		h += "<td>" + html.EscapeString(e.Schedule) + IfTrue(e.CatchUp, " ("+T("catch-up")+")") + "</td>"
+		h += "<td>" + html.EscapeString(title) + " &rarr; " + html.EscapeString(e.Action) + "</td>"
+		h += "<td" + IfTrue(e.Result == "failed", " class=\"csred\"") + IfTrue(e.Result == "ok", " class=\"csgreen\"") + ">" +
+			html.EscapeString(result) + "</td>"
+		h += "<td>" + fmt.Sprintf("%d", e.Attempt) + "</td>"
+		h += "<td>" + html.EscapeString(strings.Join(e.UpdatedIds, ", ")) + "</td>"
+		h += "</tr>"
+	}
+	h += "</table>"
+	return h
+}
+
+// The link of the history page filtered to the schedule, empty if there is no history page
+func scheduleHistoryPageLink(name string) string {
+	for i := 0; i < len(Pages); i++ {
+		if Pages[i].PageType() == ScheduleHistory {
+			return "/page/" + Pages[i].IdStr() + "?days=0&schedule=" + url.QueryEscape(name)
+		}
+	}
+	return ""
+}
+
+func (p PageScheduleHistory) IsActionIdMatch(aId string) bool {
+	return aId == "act-schedhistory-clear"
+}
+
+func (p PageScheduleHistory) HandleActionEvent(res *ActionResponse, actionName string, parameters map[string]string) {
+	if actionName == "act-schedhistory-clear" {
+		clearScheduleHistory()
+		res.addCommandArg0("refreshpage")
+		res.setResultString("ok")
+	}
+}
@@ -125,9 +125,20 @@ func (p PanelScheduleShortcut) PanelHtml(withContainer bool) string {
 	templ.Execute(&buffer, pass)
 
 	ostr := buffer.String()
-	if connectedSchedule {
-		ostr = strings.ReplaceAll(ostr, "__CLOCKSELECTOR__", htmlClockPicker("clksel"+p.IdStr(), s.hour, s.min, false, "jsfiredcs", p.idStr))
-		ostr = strings.ReplaceAll(ostr, "__DAYS__", htmlScheduleDays(s, "oneletter", false))
+	if connectedSchedule && s.kind == "cron" {
+		ostr = strings.ReplaceAll(ostr, "__CLOCKSELECTOR__", "<p class=\"text-600 body-small-styles\">"+scheduleTimeText(s)+"</p>")
+		ostr = strings.ReplaceAll(ostr, "__DAYS__", "")
+	} else if connectedSchedule {
+		hour, min, _ := scheduleTimeOnDay(s, Now())
+		ostr = strings.ReplaceAll(ostr, "__CLOCKSELECTOR__", htmlClockPicker("clksel"+p.IdStr(), hour, min, false, "jsfiredcs", p.idStr))
+		if _, hasEnd := scheduleEndTimeText(s, Now()); hasEnd {
+			ostr = strings.ReplaceAll(ostr, "__DAYS__", "<p class=\"text-600 miniature-styles\">"+scheduleRangeText(s, Now())+"</p>__DAYS__")
+		}
+		if s.kind == "date" {
+			ostr = strings.ReplaceAll(ostr, "__DAYS__", "<p class=\"text-600 body-small-styles\">"+s.dates+"</p>")
+		} else {
+			ostr = strings.ReplaceAll(ostr, "__DAYS__", htmlScheduleDays(s, "oneletter", false))
+		}
 	} else {
 		ostr = strings.ReplaceAll(ostr, "__CLOCKSELECTOR__", "")
 		ostr = strings.ReplaceAll(ostr, "__DAYS__", "")
@@ -201,6 +212,8 @@ func (p PanelScheduleShortcut) DoAction(actionName string, parameters map[string
 					if herr == nil && merr == nil {
 						s.hour = hv
 						s.min = mv
+						s.timeRef = ""
+						s.timeOffset = 0
 						GlowdashConsole.Write(fmt.Sprintf(T("Set schedule \"{{name}}\" time to &lt;{{time}}&gt;",
 							map[string]any{"name": p.scheduleName, "time": fmt.Sprintf("%02d:%02d", s.hour, s.min)})))
 						updateSchedule(idx, s)
@@ -217,6 +230,23 @@ func (p PanelScheduleShortcut) DoAction(actionName string, parameters map[string
 	return "ok", updatedIds, stateChanged
 }
 
+func (p PanelScheduleShortcut) DoActionFromScheduler(actionName string) ([]string, error) {
+	if actionName != "enable" && actionName != "disable" {
+		return []string{}, errUnknownScheduledAction
+	}
+	if !SetScheduleOnOffByName(p.scheduleName, actionName == "enable") {
+		return []string{}, fmt.Errorf("unknown schedule: %s", p.scheduleName)
+	}
+	return []string{p.idStr}, nil
+}
+
+func (p PanelScheduleShortcut) SchedulableActions() []SchedulableAction {
+	return []SchedulableAction{
+		{Name: "enable", Title: "Enable schedule"},
+		{Name: "disable", Title: "Disable schedule"},
+	}
+}
+
 func (p *PanelScheduleShortcut) QueryDevice() []string {
 	var updatedIds []string = []string{}
 
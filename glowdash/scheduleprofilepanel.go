@@ -0,0 +1,188 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+
+	"github.com/hyper-prog/smartyaml"
+)
+
+type PanelScheduleProfile struct {
+	PanelBase
+}
+
+func NewPanelScheduleProfile() *PanelScheduleProfile {
+	return &PanelScheduleProfile{
+		PanelBase{
+			idStr:        "",
+			panelType:    ScheduleProfile,
+			title:        "",
+			eventtitle:   "",
+			subPage:      "",
+			thumbImg:     "",
+			deviceType:   "",
+			hide:         false,
+			hasPowerInfo: false,
+			index:        0,
+		},
+	}
+}
+
+func (p *PanelScheduleProfile) LoadCustomConfig(sy smartyaml.SmartYAML, indexInConfig int) {
+}
+
+func (p PanelScheduleProfile) PanelHtml(withContainer bool) string {
+	templ, _ := template.New("PcT").Parse(`
+	<div class="badge badge-left" style="max-width: 100%;">
+		<div class="label label-s no-radius-bottom-left-diagonal">
+			<span class="mr-xs icon-grid icon-grid-xs"><i class="fas fa-sched3"></i></span>
+			<div class="label-value-container">
+				<p class="text-600 miniature-styles text-nowrap">{{.PTypText}}</p>
+			</div>
+		</div>
+	</div>
+
+	<div class="main-container {{if .NoProfiles}}panelnoinfo{{end}}" data-refid="b-{{.Id}}">
+		<div class="main-container-top">
+			<div class="placeholdersp"></div>
+			<div class="title-container mt-s">
+				<p class="title text-bold body-small-styles">{{.Title}}</p>
+			</div>
+			<div class="ctrlline-container mt-s">
+				<p class="text-600 title text-bold body-small-styles">{{.Profile}}</p>
+			</div>
+			{{if .ByCalendar}}
+			<div class="ctrlline-container">
+				<p class="text-600 miniature-styles">{{.ByCalendarText}}</p>
+			</div>
+			{{end}}
+		</div>
+		<div class="bottom-slot-container d-flex justify-content-center">
+			<button id="b-{{.Id}}-next" class="align-self-center device-button primary medium jsaction {{if .NoProfiles}}inactive noinfo{{end}}">
+				<span class="device-action-border">
+					<span class="device-action">
+						<span class="text-primary icon-grid icon-grid-s">
+							<i class="fa fa-sched1"></i>
+						</span>
+					</span>
+				</span>
+			</button>
+		</div>
+	</div>`)
+
+	profile, byCalendar := ScheduleProfileOn(Now())
+	activeText := profile
+	if byCalendar {
+		activeText = T("{{profile}} (selected: {{active}})", map[string]any{"profile": profile, "active": GetActiveScheduleProfile()})
+	}
+
+	pass := struct {
+		Title          string
+		Id             string
+		PTypText       string
+		Profile        string
+		ByCalendar     bool
+		ByCalendarText string
+		NoProfiles     bool
+	}{
+		Title:          p.title,
+		Id:             p.idStr,
+		PTypText:       T("Schedule profile"),
+		Profile:        activeText,
+		ByCalendar:     byCalendar,
+		ByCalendarText: T("Set by the calendar today"),
+		NoProfiles:     len(ScheduleProfiles) == 0,
+	}
+
+	buffer := bytes.Buffer{}
+	templ.Execute(&buffer, pass)
+
+	if withContainer {
+		return fmt.Sprintf("<div id=\"pc-%s\" class=\"widget-card\" tabindex=\"-1\">", p.IdStr()) +
+			buffer.String() + "</div>"
+	}
+
+	return buffer.String()
+}
+
+func (p *PanelScheduleProfile) SetHwDeviceId(id int) {
+
+}
+
+func (p *PanelScheduleProfile) RefreshHwStateIfMatch(fromPanelType PanelTypes, fromDeviceIp string, fromInDeviceId int, fromScriptName string, State int, InputState int) string {
+	return p.idStr
+}
+
+func (p PanelScheduleProfile) IsActionIdMatch(aId string) bool {
+	if "b-"+p.idStr+"-next" == aId {
+		return true
+	}
+	if "b-"+p.idStr+"-update" == aId {
+		return true
+	}
+	return false
+}
+
+// Switches to the next profile of the list
+func (p PanelScheduleProfile) DoAction(actionName string, parameters map[string]string) (string, []string, bool) {
+	var stateChanged bool = false
+	var updatedIds []string = []string{}
+	if actionName == "next" && len(ScheduleProfiles) > 0 {
+		active := GetActiveScheduleProfile()
+		next := ScheduleProfiles[0]
+		for i, name := range ScheduleProfiles {
+			if name == active && i+1 < len(ScheduleProfiles) {
+				next = ScheduleProfiles[i+1]
+			}
+		}
+		stateChanged = SetActiveScheduleProfile(next)
+		updatedIds = append(updatedIds, scheduleProfilePanelIds()...)
+	}
+	if actionName == "update" {
+		updatedIds = append(updatedIds, p.QueryDevice()...)
+	}
+	return "ok", updatedIds, stateChanged
+}
+
+// The scheduler action is profile/name, the older schedules store only the name of the profile
+func (p PanelScheduleProfile) DoActionFromScheduler(actionName string) ([]string, error) {
+	if SetActiveScheduleProfile(strings.TrimPrefix(actionName, "profile/")) {
+		return scheduleProfilePanelIds(), nil
+	}
+	return []string{}, errUnknownScheduledAction
+}
+
+func (p PanelScheduleProfile) SchedulableActions() []SchedulableAction {
+	if len(ScheduleProfiles) == 0 {
+		return []SchedulableAction{}
+	}
+	return []SchedulableAction{
+		{Name: "profile", Title: "Activate profile", Param: ParamEnum, Values: ScheduleProfiles},
+	}
+}
+
+func (p *PanelScheduleProfile) QueryDevice() []string {
+	return []string{p.idStr}
+}
+
+func (p PanelScheduleProfile) ExposeVariables() map[string]string {
+	var m map[string]string = map[string]string{}
+
+	profile, _ := ScheduleProfileOn(Now())
+	m["Panel.Id"] = p.idStr
+	m["Panel.Title"] = p.title
+	m["Panel.SubPage"] = p.subPage
+	m["Panel.Index"] = fmt.Sprintf("%d", p.index)
+	m["Panel.ActiveProfile"] = GetActiveScheduleProfile()
+	m["Panel.TodayProfile"] = profile
+	return m
+}
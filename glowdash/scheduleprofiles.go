@@ -0,0 +1,345 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The schedule profiles (like workday, homeoffice, weekend, vacation). A schedule which belongs to
+// profiles runs only when one of its profiles is active, the schedules without profile always run.
+var ScheduleProfiles []string = []string{}
+
+var activeScheduleProfile string = ""
+var scheduleProfileMutex sync.Mutex
+
+// The calendar activates profiles on the given dates, it overrides the active profile on these days
+type ScheduleCalendarSource struct {
+	source  string
+	profile string
+}
+
+var ScheduleCalendarFile string = ""
+var ScheduleCalendarICals []ScheduleCalendarSource = []ScheduleCalendarSource{}
+
+var calendarDates map[string]string = map[string]string{}
+var calendarYearly map[string]string = map[string]string{}
+var calendarMutex sync.RWMutex
+
+// The day of the last complete load of the calendar, the load is running and the errors of the last failed load
+var calendarLoadedDay string = ""
+var calendarLoading bool = false
+var calendarLastErrors string = ""
+var calendarLoadMutex sync.Mutex
+
+func isScheduleProfile(name string) bool {
+	for _, p := range ScheduleProfiles {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// The manually selected profile, the first profile is active by default
+func GetActiveScheduleProfile() string {
+	scheduleProfileMutex.Lock()
+	defer scheduleProfileMutex.Unlock()
+	if activeScheduleProfile == "" && len(ScheduleProfiles) > 0 {
+		return ScheduleProfiles[0]
+	}
+	return activeScheduleProfile
+}
+
+// Sets the active profile and saves it, returns false if the profile is unknown
+func SetActiveScheduleProfile(name string) bool {
+	if !isScheduleProfile(name) {
+		return false
+	}
+	scheduleProfileMutex.Lock()
+	changed := activeScheduleProfile != name
+	activeScheduleProfile = name
+	scheduleProfileMutex.Unlock()
+	if changed {
+		GlowdashConsole.Write(T("Active schedule profile set to &lt;{{profile}}&gt;", map[string]any{"profile": html.EscapeString(name)}))
+		SaveScheduleProfileToFile()
+		ids := scheduleProfilePanelIds()
+		if len(ids) > 0 {
+			panelUpdateRequestSSE(ids)
+		}
+	}
+	return true
+}
+
+// Returns the profile of the day, the second value is true if it comes from the calendar
+func ScheduleProfileOn(day time.Time) (string, bool) {
+	calendarMutex.RLock()
+	profile, found := calendarDates[day.Format("2006-01-02")]
+	if !found {
+		profile, found = calendarYearly[day.Format("01-02")]
+	}
+	calendarMutex.RUnlock()
+	if found {
+		return profile, true
+	}
+	return GetActiveScheduleProfile(), false
+}
+
+// Checks if one of the profiles of the schedule is active on the day
+func ScheduleProfileActiveOn(s Schedule, day time.Time) bool {
+	if strings.TrimSpace(s.profiles) == "" {
+		return true
+	}
+	profile, _ := ScheduleProfileOn(day)
+	return scheduleHasProfile(s, profile)
+}
+
+func scheduleHasProfile(s Schedule, profile string) bool {
+	for _, p := range strings.Split(s.profiles, ",") {
+		if strings.TrimSpace(p) == profile {
+			return true
+		}
+	}
+	return false
+}
+
+func SaveScheduleProfileToFile() {
+	err := writeFileAtomic(StateConfigDirectory+"/scheduleprofile.txt", []byte(GetActiveScheduleProfile()+"\n"))
+	if err != nil {
+		fmt.Printf("Cannot write scheduleprofile.txt: %s\n", err)
+	}
+}
+
+func ReadScheduleProfileFromFile() {
+	content, err := os.ReadFile(StateConfigDirectory + "/scheduleprofile.txt")
+	if err != nil {
+		return
+	}
+	name := strings.TrimSpace(string(content))
+	if isScheduleProfile(name) {
+		scheduleProfileMutex.Lock()
+		activeScheduleProfile = name
+		scheduleProfileMutex.Unlock()
+	}
+}
+
+// Parses the calendar file. The lines are "<date> <profile>" where the date is YYYY-MM-DD,
+// a YYYY-MM-DD..YYYY-MM-DD range or MM-DD (every year). The # starts a comment.
+func parseScheduleCalendarFile(content string, dates map[string]string, yearly map[string]string) []string {
+	errors := []string{}
+	for n, line := range strings.Split(content, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 || !isScheduleProfile(fields[1]) {
+			errors = append(errors, fmt.Sprintf("calendar line %d: wrong line or unknown profile", n+1))
+			continue
+		}
+		if !addCalendarDate(fields[0], fields[1], dates, yearly) {
+			errors = append(errors, fmt.Sprintf("calendar line %d: wrong date %s", n+1, fields[0]))
+		}
+	}
+	return errors
+}
+
+func addCalendarDate(date string, profile string, dates map[string]string, yearly map[string]string) bool {
+	if from, to, isRange := strings.Cut(date, ".."); isRange {
+		f, errf := time.Parse("2006-01-02", from)
+		t, errt := time.Parse("2006-01-02", to)
+		if errf != nil || errt != nil || t.Before(f) || t.Sub(f) > 366*24*time.Hour {
+			return false
+		}
+		for d := f; !d.After(t); d = d.AddDate(0, 0, 1) {
+			dates[d.Format("2006-01-02")] = profile
+		}
+		return true
+	}
+	if _, err := time.Parse("2006-01-02", date); err == nil {
+		dates[date] = profile
+		return true
+	}
+	if _, err := time.Parse("01-02", date); err == nil {
+		yearly[date] = profile
+		return true
+	}
+	return false
+}
+
+// Parses the all-day events of an iCalendar file, every event activates the profile.
+// The yearly repeated events (RRULE:FREQ=YEARLY) are added to every year.
+func parseICalendar(content string, profile string, dates map[string]string, yearly map[string]string) {
+	// Unfold the continuation lines
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.ReplaceAll(content, "\n ", "")
+	content = strings.ReplaceAll(content, "\n\t", "")
+
+	inEvent := false
+	var start, end time.Time
+	yearlyEvent := false
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "BEGIN:VEVENT" {
+			inEvent = true
+			start = time.Time{}
+			end = time.Time{}
+			yearlyEvent = false
+			continue
+		}
+		if !inEvent {
+			continue
+		}
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		property := strings.Split(name, ";")[0]
+		if property == "DTSTART" {
+			start = parseICalDate(value)
+		}
+		if property == "DTEND" {
+			end = parseICalDate(value)
+		}
+		if property == "RRULE" && strings.Contains(value, "FREQ=YEARLY") {
+			yearlyEvent = true
+		}
+		if line == "END:VEVENT" {
+			inEvent = false
+			if start.IsZero() {
+				continue
+			}
+			if end.IsZero() || !end.After(start) {
+				end = start.AddDate(0, 0, 1)
+			}
+			// The DTEND of the all-day events is exclusive
+			for d := start; d.Before(end) && d.Sub(start) <= 366*24*time.Hour; d = d.AddDate(0, 0, 1) {
+				if yearlyEvent {
+					yearly[d.Format("01-02")] = profile
+				} else {
+					dates[d.Format("2006-01-02")] = profile
+				}
+			}
+		}
+	}
+}
+
+func parseICalDate(value string) time.Time {
+	if len(value) < 8 {
+		return time.Time{}
+	}
+	t, err := time.Parse("20060102", value[:8])
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func readCalendarSource(source string) (string, error) {
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		spec := newHttpRequestSpec()
+		spec.Url = source
+		result := execHttpRequest(spec)
+		if !result.Success {
+			return "", fmt.Errorf("%s", result.ErrorMessage)
+		}
+		return string(result.Body), nil
+	}
+	content, err := os.ReadFile(source)
+	return string(content), err
+}
+
+// Loads the calendar file and the iCalendar sources, returns the number of days, the errors
+// and true if all the sources were read (the calendar is complete)
+func LoadScheduleCalendar() (int, []string, bool) {
+	complete := true
+	errors := []string{}
+	dates := map[string]string{}
+	yearly := map[string]string{}
+	for _, ical := range ScheduleCalendarICals {
+		content, err := readCalendarSource(ical.source)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %s", ical.source, err))
+			complete = false
+			continue
+		}
+		parseICalendar(content, ical.profile, dates, yearly)
+	}
+	// The entries of the calendar file are stronger than the iCalendar events
+	if ScheduleCalendarFile != "" {
+		content, err := readCalendarSource(ScheduleCalendarFile)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %s", ScheduleCalendarFile, err))
+			complete = false
+		} else {
+			errors = append(errors, parseScheduleCalendarFile(content, dates, yearly)...)
+		}
+	}
+
+	calendarMutex.Lock()
+	calendarDates = dates
+	calendarYearly = yearly
+	calendarMutex.Unlock()
+	return len(dates) + len(yearly), errors, complete
+}
+
+// Reloads the calendar once a day (the files can change and the iCalendar sources can be remote).
+// If a source can not be read, the load is retried on the next check (the same read errors are written to the console once).
+func ReloadScheduleCalendarIfRequired(now time.Time) {
+	if ScheduleCalendarFile == "" && len(ScheduleCalendarICals) == 0 {
+		return
+	}
+	day := now.Format("2006-01-02")
+	calendarLoadMutex.Lock()
+	if calendarLoadedDay == day || calendarLoading {
+		calendarLoadMutex.Unlock()
+		return
+	}
+	calendarLoading = true
+	calendarLoadMutex.Unlock()
+
+	go func() {
+		count, errors, complete := LoadScheduleCalendar()
+		calendarLoadMutex.Lock()
+		calendarLoading = false
+		newErrors := complete || strings.Join(errors, "\n") != calendarLastErrors
+		calendarLastErrors = ""
+		if complete {
+			calendarLoadedDay = day
+		} else {
+			calendarLastErrors = strings.Join(errors, "\n")
+		}
+		calendarLoadMutex.Unlock()
+
+		if newErrors {
+			for _, e := range errors {
+				GlowdashConsole.Write(T("ERROR: Schedule calendar: {{message}}", map[string]any{"message": html.EscapeString(e)}))
+			}
+		}
+		if DebugLevel > 0 {
+			fmt.Printf("Schedule calendar loaded, %d days\n", count)
+		}
+	}()
+}
+
+func scheduleProfilePanelIds() []string {
+	ids := []string{}
+	for i := 0; i < len(Panels); i++ {
+		if Panels[i].PanelType() == ScheduleProfile {
+			ids = append(ids, Panels[i].IdStr())
+		}
+	}
+	return ids
+}
@@ -0,0 +1,113 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"sync"
+	"time"
+)
+
+// The failed scheduled action waiting for retry. It is stored in a file,
+// so the retry runs after a restart too (late, if the retry time was missed).
+type ScheduleRetry struct {
+	Schedule scheduleRecord `json:"schedule"`
+	Oneshot  bool           `json:"oneshot,omitempty"`
+	Attempt  int            `json:"attempt"`
+	Due      time.Time      `json:"due"`
+	CatchUp  bool           `json:"catchup,omitempty"`
+}
+
+var scheduleRetries []ScheduleRetry = []ScheduleRetry{}
+var scheduleRetriesMutex sync.Mutex
+
+// Queues the retry of the failed scheduled action
+func addScheduleRetry(s Schedule, attempt int, due time.Time, catchUp bool) {
+	scheduleRetriesMutex.Lock()
+	scheduleRetries = append(scheduleRetries, ScheduleRetry{Schedule: scheduleToRecord(s), Oneshot: s.oneshot,
+		Attempt: attempt, Due: due, CatchUp: catchUp})
+	scheduleRetriesMutex.Unlock()
+	SaveScheduleRetriesToFile()
+}
+
+// Runs the due retries. The retry is dropped if the schedule is deleted, disabled or its action is changed meanwhile,
+// or none of its profiles is active. The condition of the current schedule is evaluated again before the retry.
+// The one shot schedules and the end actions are not in the schedule list, they are retried without checks.
+func runDueScheduleRetries(now time.Time) {
+	due := []ScheduleRetry{}
+	scheduleRetriesMutex.Lock()
+	waiting := []ScheduleRetry{}
+	for _, r := range scheduleRetries {
+		if r.Due.After(now) {
+			waiting = append(waiting, r)
+		} else {
+			due = append(due, r)
+		}
+	}
+	scheduleRetries = waiting
+	scheduleRetriesMutex.Unlock()
+	if len(due) == 0 {
+		return
+	}
+	SaveScheduleRetriesToFile()
+
+	for _, r := range due {
+		s := scheduleFromRecord(r.Schedule)
+		s.oneshot = r.Oneshot
+		if !s.oneshot {
+			scheduleMutex.Lock()
+			current := getScheduleByName(s.name)
+			scheduleMutex.Unlock()
+			if current.name != s.name || !current.enabled || current.actionId != s.actionId || current.actionParam != s.actionParam {
+				continue
+			}
+			if !ScheduleProfileActiveOn(current, now) {
+				GlowdashConsole.Write(T("Retry of schedule \"{{name}}\" dropped, its profile is not active",
+					map[string]any{"name": html.EscapeString(s.name)}))
+				continue
+			}
+			if !scheduleConditionAllows(current, r.Attempt, r.CatchUp) {
+				continue
+			}
+			s = current
+		}
+		runScheduleAction(s, r.Attempt, r.CatchUp)
+	}
+}
+
+func SaveScheduleRetriesToFile() {
+	scheduleRetriesMutex.Lock()
+	content, err := json.MarshalIndent(scheduleRetries, "", "  ")
+	scheduleRetriesMutex.Unlock()
+	if err != nil {
+		fmt.Println("Cannot encode schedule retries")
+		return
+	}
+	err = writeFileAtomic(StateConfigDirectory+"/scheduleretries.json", content)
+	if err != nil {
+		fmt.Printf("Cannot write scheduleretries.json: %s\n", err)
+	}
+}
+
+func ReadScheduleRetriesFromFile() {
+	content, err := os.ReadFile(StateConfigDirectory + "/scheduleretries.json")
+	if err != nil {
+		return
+	}
+	retries := []ScheduleRetry{}
+	if err = json.Unmarshal(content, &retries); err != nil {
+		fmt.Printf("Cannot parse scheduleretries.json: %s\n", err)
+		return
+	}
+	scheduleRetriesMutex.Lock()
+	scheduleRetries = retries
+	scheduleRetriesMutex.Unlock()
+}
@@ -8,15 +8,16 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"html"
 	"io/ioutil"
-	"os"
+	"math"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/hyper-prog/smartjson"
 )
 
 type Schedule struct {
@@ -25,9 +26,19 @@ type Schedule struct {
 	oneshot bool
 	lastrun string
 
+	// The kind of the schedule (see ScheduleKinds) and the data of the interval, cron and date kinds
+	kind     string
+	interval int
+	cron     string
+	dates    string
+
 	hour int
 	min  int
 
+	// Sun based time: the sun event (sunrise, sunset, dawn, dusk) and the offset in minutes, the hour/min is not used
+	timeRef    string
+	timeOffset int
+
 	dayMon bool
 	dayTue bool
 	dayWed bool
@@ -39,6 +50,26 @@ type Schedule struct {
 	actionType  string
 	actionId    string
 	actionParam string
+
+	// Optional condition checked at fire time: an expression (like the If command) or program:<name>
+	condition string
+
+	// Comma separated list of the schedule profiles, the schedule runs only if one of them is active (empty: always)
+	profiles string
+
+	// Catch-up window in minutes: a missed run (downtime, busy host) is fired late once within this window (0: disabled)
+	catchup int
+
+	// Random shift of the running time in ±minutes, it is the same during a day (0: exact time)
+	jitter int
+
+	// Retry policy of the failed action: the number of the retries and the delay between them in minutes
+	retries    int
+	retryDelay int
+
+	// Duration in minutes: the end action of the same panel runs after the duration (0: no end action)
+	duration  int
+	endAction string
 }
 
 var Days_oneletter_concatenated = "MTWTFSS"
@@ -63,20 +94,35 @@ var Days_short = map[int]string{
 	6: "Sun",
 }
 
-var requiredClientTranslationTexts = []string{
-	"Switch On",
-	"Switch Off",
-	"Run",
-	"Open",
-	"Close",
+// The kinds of the schedules. The empty (weekly) kind runs on the running time of the selected days,
+// the interval kind runs in every N minutes from the running time on the selected days,
+// the cron kind runs when the cron expression matches, the date kind runs on the running time of the given dates.
+var ScheduleKinds = []string{"", "interval", "cron", "date"}
+
+var scheduleKindDisplayText = map[string]string{
+	"":         "Weekly",
+	"interval": "Repeated",
+	"cron":     "Cron expression",
+	"date":     "Specific dates",
+}
+
+// The action type of the schedules by the panel type, only the panels with schedulable actions are listed
+var scheduleActionTypeNames = map[PanelTypes]string{
+	Switch:             "switch",
+	Shading:            "shading",
+	Action:             "action",
+	Script:             "script",
+	Thermostat:         "therm",
+	ThermostatSwitch:   "therm",
+	ScheduleShortcut:   "schedule",
+	ScheduleProfile:    "profile",
+	PresenceSimulation: "presence",
 }
 
-var subActionDisplayText = map[string]string{
-	"on":    "Switch On",
-	"off":   "Switch Off",
-	"run":   "Run",
-	"open":  "Open",
-	"close": "Close",
+// One option of the scheduled action selector: the stored action and its display text
+type ScheduleActionOption struct {
+	Value string `json:"v"`
+	Text  string `json:"t"`
 }
 
 var scheduleMutex sync.Mutex
@@ -85,8 +131,32 @@ var schedulesUnsaved bool = false
 var schedulesAutosaveState int = 0
 var schedulesAutosaveLimit int = 5
 
+// The time of the last scheduler check, the missed minutes between two checks are caught up
+var schedulesLastCheck time.Time
+
+// The maximum catch-up window and random shift of a schedule (minutes)
+const scheduleMaxCatchup int = 1440
+const scheduleMaxJitter int = 120
+
+// The maximum number of the retries of a failed scheduled action
+const scheduleMaxRetries int = 10
+
+// Returned by DoActionFromScheduler if the panel has no such scheduled action
+var errUnknownScheduledAction = errors.New("unknown scheduled action")
+
+// Returned by DoActionFromScheduler if the device does not respond or reports error
+var errDeviceOperationFailed = errors.New("the device operation failed")
+
+// Wraps the error of the device driver, so the history shows the reason and errors.Is still matches
+func deviceOperationError(err error) error {
+	if err == nil {
+		return errDeviceOperationFailed
+	}
+	return fmt.Errorf("%w: %w", errDeviceOperationFailed, err)
+}
+
 func nullSchedule() Schedule {
-	return Schedule{"", false, false, "", 0, 0, false, false, false, false, false, false, false, "", "", ""}
+	return Schedule{"", false, false, "", "", 0, "", "", 0, 0, "", 0, false, false, false, false, false, false, false, "", "", "", "", "", 0, 0, 0, 0, 0, ""}
 }
 
 func countSchedules() int {
@@ -155,14 +225,17 @@ func scheduleMoveDown(index int) {
 	scheduleMutex.Unlock()
 }
 
-func SetScheduleOnOffByName(name string, toState bool) {
+// Enables or disables the schedule, returns false if there is no schedule with this name
+func SetScheduleOnOffByName(name string, toState bool) bool {
 	scheduleMutex.Lock()
+	defer scheduleMutex.Unlock()
 	idx := getScheduleIndex(name)
-	if idx >= 0 && idx < len(schedules) {
-		schedules[idx].enabled = toState
-		schedulesUnsaved = true
+	if idx < 0 || idx >= len(schedules) {
+		return false
 	}
-	scheduleMutex.Unlock()
+	schedules[idx].enabled = toState
+	schedulesUnsaved = true
+	return true
 }
 
 func removeSchedule(index int) {
@@ -178,21 +251,112 @@ func removeScheduleInLock(index int) {
 	}
 }
 
-func FireSchedule(index int) {
+// Runs the action of the schedule, returns the updated panel ids
+func FireSchedule(s Schedule) ([]string, error) {
 	for i := 0; i < len(Panels); i++ {
-		if Panels[i].IdStr() == schedules[index].actionId {
+		if Panels[i].IdStr() == s.actionId {
 			if DebugLevel > 0 {
-				fmt.Printf("Scheduler execute: Panel(%s) - %s\n", schedules[index].actionId, schedules[index].actionParam)
+				fmt.Printf("Scheduler execute: Panel(%s) - %s\n", s.actionId, s.actionParam)
 			}
-			refreshIds := Panels[i].DoActionFromScheduler(schedules[index].actionParam)
+			refreshIds, err := Panels[i].DoActionFromScheduler(s.actionParam)
 			if len(refreshIds) > 0 {
 				panelUpdateRequestSSE(refreshIds)
 			}
+			return refreshIds, err
 		}
 	}
+	return []string{}, fmt.Errorf("unknown panel: %s", s.actionId)
 }
 
+// Runs the action and records the result in the history. The failed action is queued for retry
+// according to the retry policy of the schedule.
+func runScheduleAction(s Schedule, attempt int, catchUp bool) {
+	e := ScheduleHistoryEntry{Time: Now(), Schedule: s.name, PanelId: s.actionId, Action: s.actionParam,
+		Result: "ok", Attempt: attempt, CatchUp: catchUp}
+	ids, err := FireSchedule(s)
+	e.UpdatedIds = ids
+	if err != nil {
+		e.Result = "failed"
+		e.Error = err.Error()
+		GlowdashConsole.Write(T("ERROR: Schedule \"{{name}}\" failed: {{message}}",
+			map[string]any{"name": html.EscapeString(s.name), "message": html.EscapeString(err.Error())}))
+		if attempt <= s.retries {
+			delay := max(s.retryDelay, 1)
+			addScheduleRetry(s, attempt+1, Now().Truncate(time.Minute).Add(time.Duration(delay)*time.Minute), catchUp)
+			GlowdashConsole.Write(T("Schedule \"{{name}}\" is retried in {{delay}} minutes ({{retry}}/{{retries}})",
+				map[string]any{"name": html.EscapeString(s.name), "delay": delay, "retry": attempt, "retries": s.retries}))
+		}
+	} else {
+		addScheduleEnd(s, e.Time, catchUp)
+	}
+	AddScheduleHistory(e)
+}
+
+// Evaluates the condition of the schedule, the schedule without condition always runs.
+// The condition is an expression (like the If command) or program:<name> where the Return value of the program decides.
+func CheckScheduleCondition(s Schedule) (bool, error) {
+	if strings.TrimSpace(s.condition) == "" {
+		return true, nil
+	}
+	variables := map[string]string{}
+	variables["Schedule.Name"] = s.name
+	variables["Schedule.PanelId"] = s.actionId
+	variables["Schedule.Action"] = s.actionParam
+
+	programName, isProgram := strings.CutPrefix(strings.TrimSpace(s.condition), "program:")
+	if !isProgram {
+		return EvalConditionExpression(s.condition, variables), nil
+	}
+	programName = strings.TrimSpace(programName)
+	code, found := getLibraryProgram(programName)
+	if !found {
+		return false, fmt.Errorf("unknown program: %s", programName)
+	}
+	relatedPanels := []string{}
+	results := ExecuteCommandsWithOptions(code, variables, &relatedPanels, LibraryProgramRunOptions(programName))
+	if results["Error"] != "" {
+		return false, fmt.Errorf("%s", results["Error"])
+	}
+	return EvalConditionExpression(results["Return"], map[string]string{}), nil
+}
+
+// Fires the schedule if its condition is true, the skipped run is written to the console and the history
+func fireScheduleIfConditionTrue(s Schedule, catchUp bool) bool {
+	if !scheduleConditionAllows(s, 1, catchUp) {
+		return false
+	}
+	runScheduleAction(s, 1, catchUp)
+	return true
+}
+
+// Evaluates the condition of the schedule, the skipped run is recorded in the history
+func scheduleConditionAllows(s Schedule, attempt int, catchUp bool) bool {
+	run, err := CheckScheduleCondition(s)
+	if err != nil || !run {
+		e := ScheduleHistoryEntry{Time: Now(), Schedule: s.name, PanelId: s.actionId, Action: s.actionParam,
+			Result: "skipped", Attempt: attempt, CatchUp: catchUp}
+		if err != nil {
+			e.Error = "condition: " + err.Error()
+			GlowdashConsole.Write(T("ERROR: Schedule \"{{name}}\" skipped, the condition failed: {{message}}",
+				map[string]any{"name": html.EscapeString(s.name), "message": html.EscapeString(err.Error())}))
+		} else {
+			GlowdashConsole.Write(T("Schedule \"{{name}}\" skipped, the condition is false", map[string]any{"name": html.EscapeString(s.name)}))
+		}
+		AddScheduleHistory(e)
+		return false
+	}
+	return true
+}
+
+// Checks if the schedule can run on the day: one of its profiles is active (by the calendar or the active profile)
+// and the day of week is enabled. The cron and date kinds have no enabled days.
 func CheckScheduleDayEnabled(n time.Time, s Schedule) bool {
+	if !ScheduleProfileActiveOn(s, n) {
+		return false
+	}
+	if s.kind == "cron" || s.kind == "date" {
+		return true
+	}
 	if n.Weekday() == time.Monday && s.dayMon {
 		return true
 	}
@@ -202,7 +366,7 @@ func CheckScheduleDayEnabled(n time.Time, s Schedule) bool {
 	if n.Weekday() == time.Wednesday && s.dayWed {
 		return true
 	}
-	if n.Weekday() == time.Thursday && s.dayTue {
+	if n.Weekday() == time.Thursday && s.dayThu {
 		return true
 	}
 	if n.Weekday() == time.Friday && s.dayFri {
@@ -217,191 +381,382 @@ func CheckScheduleDayEnabled(n time.Time, s Schedule) bool {
 	return false
 }
 
-func CheckSchedules() {
-	current_time := time.Now()
+// Returns the running time of the schedule on the day of the given time.
+// The sun based schedules does not run on the days when the sun event is missing.
+func scheduleTimeOnDay(s Schedule, day time.Time) (int, int, bool) {
+	if s.timeRef == "" {
+		return s.hour, s.min, true
+	}
+	t, ok := SunEventTime(s.timeRef, day)
+	if !ok {
+		return 0, 0, false
+	}
+	t = t.Add(time.Duration(s.timeOffset) * time.Minute)
+	if t.Year() != day.Year() || t.YearDay() != day.YearDay() {
+		return 0, 0, false
+	}
+	return t.Hour(), t.Minute(), true
+}
 
-	schedulesAutosaveState++
-	if schedulesAutosaveState > (schedulesAutosaveLimit - 1) {
-		schedulesAutosaveState = 0
-		SaveSchedulesIfRequired()
+// The daily random shift of the schedule. It is derived from the name and the day,
+// so it does not change on restart and the catch-up finds the same time.
+func scheduleJitterOn(s Schedule, day time.Time) time.Duration {
+	if s.jitter < 1 {
+		return 0
 	}
+	h := fnv.New32a()
+	h.Write([]byte(s.name + day.Format("2006-01-02")))
+	return time.Duration(int(h.Sum32()%uint32(2*s.jitter+1))-s.jitter) * time.Minute
+}
 
-	scheduleMutex.Lock()
-	for i := 0; i < len(schedules); i++ {
-		if schedules[i].enabled {
-			if schedules[i].hour == current_time.Hour() && schedules[i].min == current_time.Minute() {
-				if CheckScheduleDayEnabled(current_time, schedules[i]) {
-					FireSchedule(i)
-					if schedules[i].oneshot {
-						removeScheduleInLock(i)
-					} else {
-						schedules[i].lastrun = fmt.Sprintf("%d-%02d-%02d %02d:%02d", current_time.Year(), current_time.Month(), current_time.Day(),
-							current_time.Hour(), current_time.Minute())
-					}
-				}
-			}
+// Checks if the schedule have to run in the minute of the given time
+func ScheduleDueAt(s Schedule, t time.Time) bool {
+	if s.jitter < 1 {
+		return scheduleDueNominal(s, t)
+	}
+	// The shift belongs to the day of the nominal run, which can be the previous or the next day near midnight
+	for d := -1; d <= 1; d++ {
+		day := t.AddDate(0, 0, d)
+		nominal := t.Add(-scheduleJitterOn(s, day))
+		if nominal.Format("2006-01-02") == day.Format("2006-01-02") && scheduleDueNominal(s, nominal) {
+			return true
 		}
 	}
-	scheduleMutex.Unlock()
+	return false
 }
 
-func schedulesGetJson() string {
-	scheduleMutex.Lock()
-	o := "{\"schedules\":["
-	haspre := false
-	for i := 0; i < len(schedules); i++ {
-		if schedules[i].oneshot {
-			continue
+// Checks if the nominal (not shifted) running time of the schedule is in the minute of the given time
+func scheduleDueNominal(s Schedule, t time.Time) bool {
+	if s.kind == "cron" {
+		c, err := ParseCronExpr(s.cron)
+		return err == nil && c.Matches(t) && CheckScheduleDayEnabled(t, s)
+	}
+	h, m, ok := scheduleTimeOnDay(s, t)
+	if !ok {
+		return false
+	}
+	if s.kind == "interval" {
+		start := h*60 + m
+		current := t.Hour()*60 + t.Minute()
+		if s.interval < 1 || current < start || (current-start)%s.interval != 0 {
+			return false
 		}
+		return CheckScheduleDayEnabled(t, s)
+	}
+	if h != t.Hour() || m != t.Minute() {
+		return false
+	}
+	if s.kind == "date" && !scheduleDateMatches(s, t) {
+		return false
+	}
+	return CheckScheduleDayEnabled(t, s)
+}
 
-		if haspre {
-			o += ","
+// Checks the dates of a date kind schedule. The dates are comma separated YYYY-MM-DD or MM-DD (every year) values.
+func scheduleDateMatches(s Schedule, t time.Time) bool {
+	full := t.Format("2006-01-02")
+	for _, d := range strings.Split(s.dates, ",") {
+		d = strings.TrimSpace(d)
+		if d == full || d == full[5:] {
+			return true
 		}
-		o += "{"
-		o += "\"name\":\"" + schedules[i].name + "\","
-		o += "\"enabled\": " + TrueFalseTextFromBool(schedules[i].enabled) + ","
-		o += "\"hour\": " + fmt.Sprintf("%d", schedules[i].hour) + ","
-		o += "\"min\": " + fmt.Sprintf("%d", schedules[i].min) + ","
+	}
+	return false
+}
 
-		o += "\"mon\": " + TrueFalseTextFromBool(schedules[i].dayMon) + ","
-		o += "\"tue\": " + TrueFalseTextFromBool(schedules[i].dayTue) + ","
-		o += "\"wed\": " + TrueFalseTextFromBool(schedules[i].dayWed) + ","
-		o += "\"thu\": " + TrueFalseTextFromBool(schedules[i].dayThu) + ","
-		o += "\"fri\": " + TrueFalseTextFromBool(schedules[i].dayFri) + ","
-		o += "\"sat\": " + TrueFalseTextFromBool(schedules[i].daySat) + ","
-		o += "\"sun\": " + TrueFalseTextFromBool(schedules[i].daySun) + ","
+// Checks the data of the interval, cron and date kinds, returns the error message or empty string
+func checkScheduleKindData(s Schedule) string {
+	if s.kind == "interval" && (s.interval < 1 || s.interval > 1440) {
+		return "The interval must be 1-1440 minutes"
+	}
+	if s.kind == "cron" {
+		if _, err := ParseCronExpr(s.cron); err != nil {
+			return err.Error()
+		}
+	}
+	if s.kind == "date" {
+		if strings.TrimSpace(s.dates) == "" {
+			return "Missing dates"
+		}
+		for _, d := range strings.Split(s.dates, ",") {
+			d = strings.TrimSpace(d)
+			_, err := time.Parse("2006-01-02", d)
+			_, errShort := time.Parse("01-02", d)
+			if err != nil && errShort != nil {
+				return "Wrong date: " + d
+			}
+		}
+	}
+	return ""
+}
 
-		o += "\"at\":\"" + schedules[i].actionType + "\","
-		o += "\"ai\":\"" + schedules[i].actionId + "\","
-		o += "\"ap\":\"" + schedules[i].actionParam + "\","
-		o += "\"lr\":\"" + schedules[i].lastrun + "\""
+// The displayed running time of the schedule, the sun based times are shown with today's time
+func scheduleTimeText(s Schedule) string {
+	if s.kind == "cron" {
+		return "cron: " + s.cron + scheduleDurationText(s)
+	}
+	text := fmt.Sprintf("%02d:%02d", s.hour, s.min)
+	if s.timeRef != "" {
+		text = T(sunEventDisplayText[s.timeRef])
+		if s.timeOffset != 0 {
+			text += fmt.Sprintf(" %+d'", s.timeOffset)
+		}
+		h, m, ok := scheduleTimeOnDay(s, Now())
+		if ok {
+			text += fmt.Sprintf(" (%02d:%02d)", h, m)
+		}
+	}
+	if s.jitter > 0 {
+		text += fmt.Sprintf(" ±%d'", s.jitter)
+	}
+	if s.duration > 0 {
+		if end, ok := scheduleEndTimeText(s, Now()); ok {
+			text += " – " + end
+		}
+	}
+	if s.kind == "interval" {
+		return T("Every {{interval}} min from {{time}}", map[string]any{"interval": s.interval, "time": text}) + scheduleDurationText(s)
+	}
+	if s.kind == "date" {
+		return s.dates + " " + text
+	}
+	return text
+}
 
-		o += "}"
-		haspre = true
+// The end time of the schedule with duration on the day (without the random shift).
+// The repeated and cron kinds run several times a day, they have no single end time.
+func scheduleEndTimeText(s Schedule, day time.Time) (string, bool) {
+	if s.duration < 1 || s.kind == "cron" || s.kind == "interval" {
+		return "", false
 	}
-	o += "]}"
-	scheduleMutex.Unlock()
-	return o
+	h, m, ok := scheduleTimeOnDay(s, day)
+	if !ok {
+		return "", false
+	}
+	end := (h*60 + m + s.duration) % 1440
+	return fmt.Sprintf("%02d:%02d", end/60, end%60), true
 }
 
-func LowerUpperCase(luc bool, str string) string {
-	if luc {
-		return strings.ToUpper(str)
+func scheduleDurationText(s Schedule) string {
+	if s.duration < 1 {
+		return ""
 	}
-	return strings.ToLower(str)
+	return " " + T("for {{duration}} min", map[string]any{"duration": s.duration})
 }
 
-func schedulesGetDbStr() string {
-	o := ""
-	scheduleMutex.Lock()
-	for i := 0; i < len(schedules); i++ {
-		if schedules[i].oneshot {
-			continue
-		}
-		if schedules[i].enabled {
-			o += "1;"
-		} else {
-			o += "0;"
-		}
-		o += schedules[i].name + ";"
-		o += fmt.Sprintf("%d;%d", schedules[i].hour, schedules[i].min) + ";"
-
-		o += LowerUpperCase(schedules[i].dayMon, "m")
-		o += LowerUpperCase(schedules[i].dayTue, "t")
-		o += LowerUpperCase(schedules[i].dayWed, "w")
-		o += LowerUpperCase(schedules[i].dayThu, "t")
-		o += LowerUpperCase(schedules[i].dayFri, "f")
-		o += LowerUpperCase(schedules[i].daySat, "s")
-		o += LowerUpperCase(schedules[i].daySun, "s")
-		o += ";"
-		o += schedules[i].actionType + ":" + schedules[i].actionId + ":" + schedules[i].actionParam + ";"
-		o += schedules[i].lastrun + "\n"
+// The displayed running time range like "05:30 – 06:15", or the running time if the schedule has no duration
+func scheduleRangeText(s Schedule, day time.Time) string {
+	h, m, ok := scheduleTimeOnDay(s, day)
+	if !ok {
+		return ""
 	}
-	scheduleMutex.Unlock()
-	return o
+	text := fmt.Sprintf("%02d:%02d", h, m)
+	if end, ok := scheduleEndTimeText(s, day); ok {
+		text += " – " + end
+	}
+	return text
 }
 
-func SaveSchedulesToFileJson() {
-	f, err := os.Create(StateConfigDirectory + "/schedules.json")
+// The last run is stored in RFC3339 format, the old "YYYY-MM-DD HH:MM" format is accepted too
+func scheduleLastrunTime(s Schedule) (time.Time, bool) {
+	if s.lastrun == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, s.lastrun)
 	if err != nil {
-		fmt.Println("Cannot create schedules.json")
-		return
+		t, err = time.ParseInLocation("2006-01-02 15:04", s.lastrun, TimeLocation)
+		if err != nil {
+			return time.Time{}, false
+		}
 	}
-	defer f.Close()
+	return t, true
+}
 
-	if DebugLevel > 0 {
-		fmt.Println("Writing schedules.json")
+func scheduleLastrunText(s Schedule) string {
+	t, ok := scheduleLastrunTime(s)
+	if !ok {
+		return s.lastrun
 	}
+	return t.In(TimeLocation).Format("2006-01-02 15:04")
+}
 
-	_, err = f.Write([]byte(schedulesGetJson()))
-	if err != nil {
-		fmt.Println("Cannot write schedules.json")
-		return
+// Searches the latest missed running time of the schedule after the given time and within its catch-up window.
+// The current minute is not checked, that is the normal run.
+func scheduleMissedRunTime(s Schedule, after time.Time, now time.Time) (time.Time, bool) {
+	if s.catchup < 1 || s.oneshot || after.IsZero() {
+		return time.Time{}, false
+	}
+	window := min(s.catchup, scheduleMaxCatchup)
+	current := now.Truncate(time.Minute)
+	for m := 1; m <= window; m++ {
+		t := current.Add(-time.Duration(m) * time.Minute)
+		if !t.After(after) {
+			break
+		}
+		if ScheduleDueAt(s, t) && (!dstRepeatedMinute(t) || scheduleRunsInRepeatedHour(s)) {
+			return t, true
+		}
 	}
-	schedulesUnsaved = false
+	return time.Time{}, false
 }
 
-func SaveSchedulesToFileDb() {
-	f, err := os.Create(StateConfigDirectory + "/schedules.db")
-	if err != nil {
-		fmt.Println("Cannot create schedules.db")
-		return
+// Checks if the schedule runs in the second occurrence of the wall clock minutes repeated by the DST change.
+// Like the classic cron: the schedules running more times an hour (interval below an hour, cron for every hour)
+// and the one shot schedules keep running, the schedules of fixed times run only at the first occurrence.
+func scheduleRunsInRepeatedHour(s Schedule) bool {
+	if s.oneshot {
+		return true
 	}
-	defer f.Close()
-
-	if DebugLevel > 0 {
-		fmt.Println("Writing schedules.db")
+	if s.kind == "interval" {
+		return s.interval > 0 && s.interval < 60
 	}
+	if s.kind == "cron" {
+		c, err := ParseCronExpr(s.cron)
+		return err == nil && c.EveryHour()
+	}
+	return false
+}
 
-	_, err = f.Write([]byte(schedulesGetDbStr()))
-	if err != nil {
-		fmt.Println("Cannot write schedules.db")
-		return
+// Checks if the schedule was due in one of the minutes skipped by the DST change
+func scheduleDueInSkipped(s Schedule, skipped []time.Time) bool {
+	for _, t := range skipped {
+		if ScheduleDueAt(s, t) {
+			return true
+		}
 	}
-	schedulesUnsaved = false
+	return false
 }
 
-func ReadSchedulesFromFileJson() {
-	content, err := ioutil.ReadFile(StateConfigDirectory + "/schedules.json")
-	if err == nil {
-		sj, parserror := smartjson.ParseJSON(content)
-		if parserror == nil {
-			scheduleMutex.Lock()
-			schedules = []Schedule{}
-			schedulesarray, _ := sj.GetArrayByPath("/schedules")
-			sl := len(schedulesarray)
-			for i := 0; i < sl; i++ {
-				var s Schedule
-				s.name = sj.GetStringByPathWithDefault(fmt.Sprintf("/schedules/[%d]/name", i), "")
-				s.enabled = sj.GetBoolByPathWithDefault(fmt.Sprintf("/schedules/[%d]/enabled", i), false)
-				s.hour = int(sj.GetFloat64ByPathWithDefault(fmt.Sprintf("/schedules/[%d]/hour", i), 0.0))
-				s.min = int(sj.GetFloat64ByPathWithDefault(fmt.Sprintf("/schedules/[%d]/min", i), 0.0))
-
-				s.dayMon = sj.GetBoolByPathWithDefault(fmt.Sprintf("/schedules/[%d]/mon", i), false)
-				s.dayTue = sj.GetBoolByPathWithDefault(fmt.Sprintf("/schedules/[%d]/tue", i), false)
-				s.dayWed = sj.GetBoolByPathWithDefault(fmt.Sprintf("/schedules/[%d]/wed", i), false)
-				s.dayThu = sj.GetBoolByPathWithDefault(fmt.Sprintf("/schedules/[%d]/thu", i), false)
-				s.dayFri = sj.GetBoolByPathWithDefault(fmt.Sprintf("/schedules/[%d]/fri", i), false)
-				s.daySat = sj.GetBoolByPathWithDefault(fmt.Sprintf("/schedules/[%d]/sat", i), false)
-				s.daySun = sj.GetBoolByPathWithDefault(fmt.Sprintf("/schedules/[%d]/sun", i), false)
-
-				s.actionType = sj.GetStringByPathWithDefault(fmt.Sprintf("/schedules/[%d]/at", i), "")
-				s.actionId = sj.GetStringByPathWithDefault(fmt.Sprintf("/schedules/[%d]/ai", i), "")
-				s.actionParam = sj.GetStringByPathWithDefault(fmt.Sprintf("/schedules/[%d]/ap", i), "")
-
-				s.lastrun = sj.GetStringByPathWithDefault(fmt.Sprintf("/schedules/[%d]/lr", i), "")
-
-				if s.name != "" && s.actionType != "" && s.actionId != "" && s.actionParam != "" {
-					addScheduleLowlevel(s)
+func CheckSchedules() {
+	current_time := Now()
+	ReloadScheduleCalendarIfRequired(current_time)
+
+	schedulesAutosaveState++
+	if schedulesAutosaveState > (schedulesAutosaveLimit - 1) {
+		schedulesAutosaveState = 0
+		SaveSchedulesIfRequired()
+	}
+
+	// The due schedules are fired out of the lock, because the actions and conditions can change the schedules
+	// The second occurrence of the wall clock minutes repeated by the DST change runs only the hourly schedules,
+	// the schedules of the minutes skipped by the DST change are run in the first minute after the change.
+	repeated := dstRepeatedMinute(current_time)
+	skipped := dstSkippedMinutes(current_time)
+
+	due := []Schedule{}
+	caughtUp := map[string]time.Time{}
+	scheduleMutex.Lock()
+	for i := 0; i < len(schedules); i++ {
+		if schedules[i].enabled && (!repeated || scheduleRunsInRepeatedHour(schedules[i])) {
+			if ScheduleDueAt(schedules[i], current_time) || scheduleDueInSkipped(schedules[i], skipped) {
+				due = append(due, schedules[i])
+				if schedules[i].oneshot {
+					removeScheduleInLock(i)
+					i--
 				}
+				continue
+			}
+			// After a restart the last run is the reference, otherwise the previous check
+			after := schedulesLastCheck
+			if lastrun, ok := scheduleLastrunTime(schedules[i]); ok && (after.IsZero() || lastrun.After(after)) {
+				after = lastrun
+			}
+			if missed, found := scheduleMissedRunTime(schedules[i], after, current_time); found {
+				due = append(due, schedules[i])
+				caughtUp[schedules[i].name] = missed
+			}
+		}
+	}
+	schedulesLastCheck = current_time.Truncate(time.Minute)
+	scheduleMutex.Unlock()
+
+	for _, s := range due {
+		missed, catchUp := caughtUp[s.name]
+		if catchUp {
+			GlowdashConsole.Write(T("Catch-up of schedule \"{{name}}\", it was due at {{time}}",
+				map[string]any{"name": html.EscapeString(s.name), "time": missed.Format("2006-01-02 15:04")}))
+		}
+		if fireScheduleIfConditionTrue(s, catchUp) && !s.oneshot {
+			scheduleMutex.Lock()
+			idx := getScheduleIndex(s.name)
+			if idx >= 0 {
+				schedules[idx].lastrun = current_time.Format(time.RFC3339)
+				schedulesUnsaved = true
 			}
 			scheduleMutex.Unlock()
 		}
 	}
-	schedulesUnsaved = false
+
+	runDueScheduleEnds(current_time)
+	runDueScheduleRetries(current_time)
 }
 
-func ReadSchedulesFromFileDb() {
+func setScheduleDbExtraField(s *Schedule, key string, value string) {
+	if key == "sun" {
+		event, offset, ok := ParseSunTimeSpec(value)
+		if ok {
+			s.timeRef = event
+			s.timeOffset = offset
+		}
+	}
+	if key == "kind" {
+		s.kind = value
+	}
+	if key == "every" {
+		interval, err := strconv.Atoi(value)
+		if err == nil {
+			s.interval = interval
+		}
+	}
+	if key == "cron" {
+		s.cron = value
+	}
+	if key == "dates" {
+		s.dates = value
+	}
+	if key == "cond" {
+		s.condition = value
+	}
+	if key == "profiles" {
+		s.profiles = value
+	}
+	if key == "catchup" {
+		catchup, err := strconv.Atoi(value)
+		if err == nil {
+			s.catchup = catchup
+		}
+	}
+	if key == "jitter" {
+		jitter, err := strconv.Atoi(value)
+		if err == nil {
+			s.jitter = jitter
+		}
+	}
+	if key == "retries" {
+		retries, err := strconv.Atoi(value)
+		if err == nil {
+			s.retries = retries
+		}
+	}
+	if key == "retrydelay" {
+		delay, err := strconv.Atoi(value)
+		if err == nil {
+			s.retryDelay = delay
+		}
+	}
+	if key == "duration" {
+		duration, err := strconv.Atoi(value)
+		if err == nil {
+			s.duration = duration
+		}
+	}
+	if key == "endaction" {
+		s.endAction = value
+	}
+}
+
+// Reads the schedules from the old db format, it is used only to migrate the schedules to the json format
+func ReadSchedulesFromFileDb() bool {
 	content, err := ioutil.ReadFile(StateConfigDirectory + "/schedules.db")
 	if err == nil {
 		scheduleMutex.Lock()
@@ -500,9 +855,15 @@ func ReadSchedulesFromFileDb() {
 			s.actionId = actparts[1]
 			s.actionParam = actparts[2]
 
-			if len(lineparts) > 5 && lineparts[6] != "" {
+			if len(lineparts) > 6 && lineparts[6] != "" {
 				s.lastrun = lineparts[6]
 			}
+			for f := 7; f < len(lineparts); f++ {
+				key, value, found := strings.Cut(lineparts[f], "=")
+				if found {
+					setScheduleDbExtraField(&s, key, value)
+				}
+			}
 
 			if s.name != "" && s.actionType != "" && s.actionId != "" && s.actionParam != "" {
 				addScheduleLowlevel(s)
@@ -510,35 +871,140 @@ func ReadSchedulesFromFileDb() {
 		}
 		scheduleMutex.Unlock()
 	}
-	schedulesUnsaved = false
+	return err == nil
 }
 
-func SaveSchedulesIfRequired() {
-	if schedulesUnsaved {
-		SaveSchedulesToFileDb()
+func getScheduleActionTypeByPanelId(panelId string) string {
+	panel := GetPanelById(panelId)
+	if panel == nil || len(panel.SchedulableActions()) == 0 {
+		return ""
 	}
+	return scheduleActionTypeNames[panel.PanelType()]
 }
 
-func getScheduleActionTypeByPanelId(panelId string) string {
-	panelcnt := len(Panels)
-	for i := 0; i < panelcnt; i++ {
-		if Panels[i].IdStr() == panelId {
-			if Panels[i].PanelType() == Switch {
-				return "switch"
+// The options of the scheduled action selector generated from the schedulable actions of a panel
+func scheduleActionOptions(actions []SchedulableAction) []ScheduleActionOption {
+	options := []ScheduleActionOption{}
+	for _, a := range actions {
+		if a.Param == ParamNone {
+			options = append(options, ScheduleActionOption{Value: a.Name, Text: T(a.Title)})
+		}
+		if a.Param == ParamBool {
+			options = append(options, ScheduleActionOption{Value: a.Name + "/on", Text: T(a.Title) + ": " + T("ON")})
+			options = append(options, ScheduleActionOption{Value: a.Name + "/off", Text: T(a.Title) + ": " + T("OFF")})
+		}
+		if a.Param == ParamNumber {
+			step := a.Step
+			if step <= 0 {
+				step = 1
+			}
+			count := int(math.Round((a.Max - a.Min) / step))
+			for i := 0; i <= count; i++ {
+				value := strconv.FormatFloat(a.Min+float64(i)*step, 'f', -1, 64)
+				options = append(options, ScheduleActionOption{Value: a.Name + "/" + value, Text: T(a.Title) + ": " + value})
 			}
-			if Panels[i].PanelType() == Shading {
-				return "shading"
+		}
+		if a.Param == ParamEnum {
+			for _, value := range a.Values {
+				options = append(options, ScheduleActionOption{Value: a.Name + "/" + value, Text: T(a.Title) + ": " + value})
 			}
-			if Panels[i].PanelType() == Action {
-				return "action"
+		}
+	}
+	return options
+}
+
+// Returns the schedulable action of the given name
+func findSchedulableAction(actions []SchedulableAction, name string) (SchedulableAction, bool) {
+	for _, a := range actions {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return SchedulableAction{}, false
+}
+
+// Checks the parameter value of the schedulable action: on or off for the bool, a number of the range
+// for the number and one of the values for the enum parameter
+func checkSchedulableActionParam(a SchedulableAction, value string) error {
+	switch a.Param {
+	case ParamNone:
+		return fmt.Errorf("the action %s has no parameter", a.Name)
+	case ParamBool:
+		if value != "on" && value != "off" {
+			return fmt.Errorf("the parameter of the action %s must be on or off", a.Name)
+		}
+	case ParamNumber:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(v) || v < a.Min || v > a.Max {
+			return fmt.Errorf("the parameter of the action %s must be a number between %g and %g", a.Name, a.Min, a.Max)
+		}
+	case ParamEnum:
+		for _, allowed := range a.Values {
+			if value == allowed {
+				return nil
 			}
-			if Panels[i].PanelType() == Script {
-				return "script"
+		}
+		return fmt.Errorf("the parameter of the action %s must be one of: %s", a.Name, strings.Join(a.Values, ", "))
+	}
+	return nil
+}
+
+// Checks the stored scheduled action (name or name/value) against the schedulable actions of the panel.
+// The older schedules store only the value of the parameter, it is accepted for the parameterized actions.
+func checkSchedulableAction(actions []SchedulableAction, stored string) error {
+	name, value, hasValue := strings.Cut(stored, "/")
+	if a, found := findSchedulableAction(actions, name); found {
+		if a.Param == ParamNone {
+			if hasValue {
+				return fmt.Errorf("the action %s has no parameter", a.Name)
 			}
-			if Panels[i].PanelType() == Thermostat {
-				return "therm"
+			return nil
+		}
+		if !hasValue {
+			return fmt.Errorf("the action %s requires a parameter", a.Name)
+		}
+		return checkSchedulableActionParam(a, value)
+	}
+	if !hasValue {
+		for _, a := range actions {
+			if a.Param != ParamNone && checkSchedulableActionParam(a, stored) == nil {
+				return nil
 			}
 		}
 	}
-	return ""
+	return fmt.Errorf("unknown action: %s", stored)
+}
+
+// Checks if the stored action is the option. The older schedules store only the value of the parameter.
+func scheduleActionOptionMatches(option ScheduleActionOption, stored string) bool {
+	if option.Value == stored {
+		return true
+	}
+	_, value, found := strings.Cut(option.Value, "/")
+	if !found || stored == "" || strings.Contains(stored, "/") {
+		return false
+	}
+	if value == stored {
+		return true
+	}
+	v, errv := strconv.ParseFloat(value, 64)
+	s, errs := strconv.ParseFloat(stored, 64)
+	return errv == nil && errs == nil && v == s
+}
+
+// The display text of the scheduled action of the panel
+func scheduleActionDisplayText(panelId string, stored string) string {
+	if stored == "" {
+		return T("Nothing")
+	}
+	panel := GetPanelById(panelId)
+	if panel == nil {
+		return stored
+	}
+	for _, option := range scheduleActionOptions(panel.SchedulableActions()) {
+		if scheduleActionOptionMatches(option, stored) {
+			return option.Text
+		}
+	}
+	return stored
 }
@@ -0,0 +1,286 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// The version of the schedules file. The files without version are the older json files (same keys).
+const schedulesFileVersion int = 2
+
+// Serializes the writes of the schedules file, so an older state can not overwrite a newer one
+var schedulesSaveMutex sync.Mutex
+
+// The content of the schedules file and the exported schedule set
+type schedulesFile struct {
+	Version   int              `json:"version"`
+	Saved     string           `json:"saved,omitempty"`
+	Schedules []scheduleRecord `json:"schedules"`
+}
+
+type scheduleRecord struct {
+	Name       string `json:"name"`
+	Enabled    bool   `json:"enabled"`
+	Hour       int    `json:"hour"`
+	Min        int    `json:"min"`
+	TimeRef    string `json:"tref"`
+	TimeOffset int    `json:"toff"`
+	Kind       string `json:"kind"`
+	Interval   int    `json:"every"`
+	Cron       string `json:"cron"`
+	Dates      string `json:"dates"`
+	Profiles   string `json:"profiles"`
+	Condition  string `json:"cond"`
+	Catchup    int    `json:"catchup"`
+	Jitter     int    `json:"jitter"`
+	Retries    int    `json:"retries"`
+	RetryDelay int    `json:"retrydelay"`
+	Duration   int    `json:"duration"`
+	EndAction  string `json:"endaction"`
+
+	Mon bool `json:"mon"`
+	Tue bool `json:"tue"`
+	Wed bool `json:"wed"`
+	Thu bool `json:"thu"`
+	Fri bool `json:"fri"`
+	Sat bool `json:"sat"`
+	Sun bool `json:"sun"`
+
+	ActionType  string `json:"at"`
+	ActionId    string `json:"ai"`
+	ActionParam string `json:"ap"`
+	Lastrun     string `json:"lr"`
+}
+
+func scheduleToRecord(s Schedule) scheduleRecord {
+	return scheduleRecord{
+		Name: s.name, Enabled: s.enabled, Hour: s.hour, Min: s.min, TimeRef: s.timeRef, TimeOffset: s.timeOffset,
+		Kind: s.kind, Interval: s.interval, Cron: s.cron, Dates: s.dates, Profiles: s.profiles, Condition: s.condition,
+		Catchup: s.catchup, Jitter: s.jitter, Retries: s.retries, RetryDelay: s.retryDelay,
+		Duration: s.duration, EndAction: s.endAction,
+		Mon: s.dayMon, Tue: s.dayTue, Wed: s.dayWed, Thu: s.dayThu, Fri: s.dayFri, Sat: s.daySat, Sun: s.daySun,
+		ActionType: s.actionType, ActionId: s.actionId, ActionParam: s.actionParam, Lastrun: s.lastrun,
+	}
+}
+
+func scheduleFromRecord(r scheduleRecord) Schedule {
+	s := nullSchedule()
+	s.name, s.enabled, s.hour, s.min, s.timeRef, s.timeOffset = r.Name, r.Enabled, r.Hour, r.Min, r.TimeRef, r.TimeOffset
+	s.kind, s.interval, s.cron, s.dates, s.profiles, s.condition = r.Kind, r.Interval, r.Cron, r.Dates, r.Profiles, r.Condition
+	s.catchup, s.jitter, s.retries, s.retryDelay = r.Catchup, r.Jitter, r.Retries, r.RetryDelay
+	s.duration, s.endAction = r.Duration, r.EndAction
+	s.dayMon, s.dayTue, s.dayWed, s.dayThu, s.dayFri, s.daySat, s.daySun = r.Mon, r.Tue, r.Wed, r.Thu, r.Fri, r.Sat, r.Sun
+	s.actionType, s.actionId, s.actionParam, s.lastrun = r.ActionType, r.ActionId, r.ActionParam, r.Lastrun
+	return s
+}
+
+// Encodes the schedules except the one shot ones (they are not kept over restart)
+func encodeSchedules(list []Schedule) ([]byte, error) {
+	f := schedulesFile{Version: schedulesFileVersion, Saved: Now().Format(time.RFC3339), Schedules: []scheduleRecord{}}
+	for _, s := range list {
+		if !s.oneshot {
+			f.Schedules = append(f.Schedules, scheduleToRecord(s))
+		}
+	}
+	return json.MarshalIndent(f, "", "  ")
+}
+
+// Decodes and checks the schedules. The schedules without name or action and the wrong kind data are invalid,
+// they are left out of the list and returned as the second value. The error is returned if the file is unreadable.
+func decodeSchedules(content []byte) ([]Schedule, []error, error) {
+	var f schedulesFile
+	if err := json.Unmarshal(content, &f); err != nil {
+		return nil, nil, err
+	}
+	if f.Version > schedulesFileVersion {
+		return nil, nil, fmt.Errorf("unsupported version: %d", f.Version)
+	}
+	list := []Schedule{}
+	invalid := []error{}
+	names := map[string]bool{}
+	for i, r := range f.Schedules {
+		s := scheduleFromRecord(r)
+		if s.name == "" {
+			invalid = append(invalid, fmt.Errorf("the schedule %d has no name", i+1))
+			continue
+		}
+		if names[s.name] {
+			invalid = append(invalid, fmt.Errorf("duplicated schedule name: %s", s.name))
+			continue
+		}
+		if err := checkSchedule(s); err != nil {
+			invalid = append(invalid, fmt.Errorf("%s: %s", s.name, err))
+			continue
+		}
+		names[s.name] = true
+		list = append(list, s)
+	}
+	return list, invalid, nil
+}
+
+// Checks the data of a schedule read from file, import or the api
+func checkSchedule(s Schedule) error {
+	if s.name == "" || s.actionId == "" || s.actionParam == "" {
+		return fmt.Errorf("missing name or action")
+	}
+	if s.hour < 0 || s.hour > 23 || s.min < 0 || s.min > 59 {
+		return fmt.Errorf("wrong time: %d:%d", s.hour, s.min)
+	}
+	if s.timeRef != "" && !isSunEvent(s.timeRef) {
+		return fmt.Errorf("wrong sun event: %s", s.timeRef)
+	}
+	if s.catchup < 0 || s.catchup > scheduleMaxCatchup || s.jitter < 0 || s.jitter > scheduleMaxJitter ||
+		s.retries < 0 || s.retries > scheduleMaxRetries || s.retryDelay < 0 || s.retryDelay > 1440 ||
+		s.duration < 0 || s.duration > scheduleMaxDuration {
+		return fmt.Errorf("the catch-up, random shift, retry or duration value is out of range")
+	}
+	if message := checkScheduleKindData(s); message != "" {
+		return fmt.Errorf("%s", message)
+	}
+	return nil
+}
+
+// Saves the schedules atomically, the previous file is kept as schedules.json.bak
+func SaveSchedulesToFile() {
+	schedulesSaveMutex.Lock()
+	defer schedulesSaveMutex.Unlock()
+
+	scheduleMutex.Lock()
+	content, err := encodeSchedules(schedules)
+	schedulesUnsaved = false
+	scheduleMutex.Unlock()
+	if err != nil {
+		fmt.Println("Cannot encode schedules")
+		return
+	}
+
+	if DebugLevel > 0 {
+		fmt.Println("Writing schedules.json")
+	}
+
+	// The backup is a copy, so the schedules.json exists all the time
+	filename := StateConfigDirectory + "/schedules.json"
+	if previous, err := os.ReadFile(filename); err == nil {
+		if err := writeFileAtomic(filename+".bak", previous); err != nil {
+			fmt.Printf("Cannot backup schedules.json: %s\n", err)
+		}
+	}
+	if err := writeFileAtomic(filename, content); err != nil {
+		fmt.Printf("Cannot write schedules.json: %s\n", err)
+		scheduleMutex.Lock()
+		schedulesUnsaved = true
+		scheduleMutex.Unlock()
+	}
+}
+
+// Reads the schedules.json, or its backup if it is missing or broken. The invalid schedules are skipped.
+// The schedules of the old schedules.db are migrated only if there is no json file at all,
+// the migrated schedules.db is renamed to schedules.db.migrated.
+func ReadSchedulesFromFile() {
+	filename := StateConfigDirectory + "/schedules.json"
+	jsonExists := false
+	for _, name := range []string{filename, filename + ".bak"} {
+		content, err := os.ReadFile(name)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				jsonExists = true
+			}
+			continue
+		}
+		jsonExists = true
+		list, invalid, err := decodeSchedules(content)
+		if err != nil {
+			GlowdashConsole.Write(T("ERROR: Cannot read the schedules from {{file}}: {{message}}",
+				map[string]any{"file": name, "message": err.Error()}))
+			continue
+		}
+		for _, ierr := range invalid {
+			GlowdashConsole.Write(T("ERROR: Invalid schedule skipped in {{file}}: {{message}}",
+				map[string]any{"file": name, "message": ierr.Error()}))
+		}
+		scheduleMutex.Lock()
+		schedules = list
+		schedulesUnsaved = name != filename
+		scheduleMutex.Unlock()
+		return
+	}
+	if jsonExists {
+		return
+	}
+
+	if ReadSchedulesFromFileDb() {
+		SaveSchedulesToFile()
+		// The schedules.db is kept until the json file is written, so a failed write is migrated again on the next start
+		if _, err := os.Stat(filename); err == nil {
+			dbname := StateConfigDirectory + "/schedules.db"
+			if err := os.Rename(dbname, dbname+".migrated"); err != nil {
+				fmt.Printf("Cannot rename schedules.db: %s\n", err)
+			}
+		}
+		GlowdashConsole.Write(T("The schedules are migrated from schedules.db"))
+	}
+}
+
+func SaveSchedulesIfRequired() {
+	scheduleMutex.Lock()
+	unsaved := schedulesUnsaved
+	scheduleMutex.Unlock()
+	if unsaved {
+		SaveSchedulesToFile()
+	}
+}
+
+// Imports a schedule set. The replace mode drops the current schedules, otherwise the schedules
+// with the same name are overwritten and the others are added. Returns the number of the imported schedules.
+func importSchedules(content []byte, replace bool) (int, error) {
+	list, invalid, err := decodeSchedules(content)
+	if err != nil {
+		return 0, err
+	}
+	if len(invalid) > 0 {
+		return 0, invalid[0]
+	}
+	scheduleMutex.Lock()
+	if replace {
+		schedules = []Schedule{}
+	}
+	for _, s := range list {
+		if idx := getScheduleIndex(s.name); idx >= 0 {
+			schedules[idx] = s
+		} else {
+			schedules = append(schedules, s)
+		}
+	}
+	schedulesUnsaved = true
+	scheduleMutex.Unlock()
+	SaveSchedulesToFile()
+	return len(list), nil
+}
+
+// Downloads the schedule set in the format of the schedules file
+func handleSchedulesExport(w http.ResponseWriter, r *http.Request) {
+	scheduleMutex.Lock()
+	content, err := encodeSchedules(schedules)
+	scheduleMutex.Unlock()
+	if err != nil {
+		http.Error(w, "500 Internal Server Error", 500)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	w.Header().Add("Content-Disposition", "attachment; filename=\"glowdash-schedules.json\"")
+	io.WriteString(w, string(content))
+}
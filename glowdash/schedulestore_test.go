@@ -0,0 +1,172 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func setupScheduleStore(t *testing.T) string {
+	t.Helper()
+	MaxLogLines = 10
+	GlowdashConsole.Init()
+	StateConfigDirectory = t.TempDir()
+	savedSchedules := schedules
+	schedules = []Schedule{}
+	t.Cleanup(func() { schedules = savedSchedules })
+	return StateConfigDirectory
+}
+
+func TestSchedulesRoundTrip(t *testing.T) {
+	daily := nullSchedule()
+	daily.name, daily.enabled, daily.hour, daily.min = "daily", true, 7, 30
+	daily.dayMon, daily.dayFri, daily.daySun = true, true, true
+	daily.actionType, daily.actionId, daily.actionParam = "switch", "sw1", "on"
+	daily.profiles, daily.condition, daily.lastrun = "home", "1 == 1", "2026-06-01 07:30"
+	daily.catchup, daily.jitter, daily.retries, daily.retryDelay = 30, 5, 2, 10
+	daily.duration, daily.endAction = 60, "off"
+
+	sun := nullSchedule()
+	sun.name, sun.timeRef, sun.timeOffset = "sunset", "sunset", -15
+	sun.actionType, sun.actionId, sun.actionParam = "shading", "sh1", "close"
+
+	cron := nullSchedule()
+	cron.name, cron.kind, cron.cron = "cron", "cron", "*/15 8-18 * * 1-5"
+	cron.actionType, cron.actionId, cron.actionParam = "script", "sc1", "run"
+
+	interval := nullSchedule()
+	interval.name, interval.kind, interval.interval = "interval", "interval", 45
+	interval.actionType, interval.actionId, interval.actionParam = "switch", "sw2", "toggle"
+
+	date := nullSchedule()
+	date.name, date.kind, date.dates = "date", "date", "2026-12-24,01-01"
+	date.actionType, date.actionId, date.actionParam = "switch", "sw3", "on"
+
+	oneshot := nullSchedule()
+	oneshot.name, oneshot.oneshot = "oneshot", true
+	oneshot.actionType, oneshot.actionId, oneshot.actionParam = "switch", "sw4", "on"
+
+	content, err := encodeSchedules([]Schedule{daily, sun, cron, interval, date, oneshot})
+	if err != nil {
+		t.Fatalf("cannot encode: %s", err)
+	}
+	list, invalid, err := decodeSchedules(content)
+	if err != nil {
+		t.Fatalf("cannot decode: %s", err)
+	}
+	if len(invalid) > 0 {
+		t.Fatalf("invalid schedules: %v", invalid)
+	}
+	want := []Schedule{daily, sun, cron, interval, date}
+	if !reflect.DeepEqual(list, want) {
+		t.Errorf("got %+v\nwant %+v", list, want)
+	}
+}
+
+func TestDecodeSchedules(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		fail    bool
+		names   []string
+		invalid int
+	}{
+		{"current version", `{"version":2,"schedules":[{"name":"a","ai":"sw1","ap":"on"}]}`, false, []string{"a"}, 0},
+		{"file without version", `{"schedules":[{"name":"a","ai":"sw1","ap":"on"}]}`, false, []string{"a"}, 0},
+		{"empty", `{"version":2,"schedules":[]}`, false, []string{}, 0},
+		{"unknown version", `{"version":3,"schedules":[{"name":"a","ai":"sw1","ap":"on"}]}`, true, nil, 0},
+		{"broken json", `{"version":2,"schedules":[`, true, nil, 0},
+		{"missing name", `{"version":2,"schedules":[{"ai":"sw1","ap":"on"},{"name":"b","ai":"sw1","ap":"on"}]}`, false, []string{"b"}, 1},
+		{"missing action", `{"version":2,"schedules":[{"name":"a","ai":"sw1"}]}`, false, []string{}, 1},
+		{"duplicated name", `{"version":2,"schedules":[{"name":"a","ai":"sw1","ap":"on"},{"name":"a","ai":"sw2","ap":"on"}]}`, false, []string{"a"}, 1},
+		{"wrong time", `{"version":2,"schedules":[{"name":"a","hour":24,"ai":"sw1","ap":"on"}]}`, false, []string{}, 1},
+		{"wrong sun event", `{"version":2,"schedules":[{"name":"a","tref":"noon","ai":"sw1","ap":"on"}]}`, false, []string{}, 1},
+		{"wrong cron", `{"version":2,"schedules":[{"name":"a","kind":"cron","cron":"* *","ai":"sw1","ap":"on"}]}`, false, []string{}, 1},
+		{"wrong interval", `{"version":2,"schedules":[{"name":"a","kind":"interval","every":0,"ai":"sw1","ap":"on"}]}`, false, []string{}, 1},
+		{"wrong retries", `{"version":2,"schedules":[{"name":"a","retries":-1,"ai":"sw1","ap":"on"}]}`, false, []string{}, 1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			list, invalid, err := decodeSchedules([]byte(tc.content))
+			if tc.fail {
+				if err == nil {
+					t.Fatalf("the content is accepted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("the content is refused: %s", err)
+			}
+			names := []string{}
+			for _, s := range list {
+				names = append(names, s.name)
+			}
+			if !reflect.DeepEqual(names, tc.names) {
+				t.Errorf("got schedules %v, want %v", names, tc.names)
+			}
+			if len(invalid) != tc.invalid {
+				t.Errorf("got %d invalid schedules, want %d: %v", len(invalid), tc.invalid, invalid)
+			}
+		})
+	}
+}
+
+func TestReadSchedulesUnknownVersion(t *testing.T) {
+	dir := setupScheduleStore(t)
+	os.WriteFile(dir+"/schedules.json", []byte(`{"version":99,"schedules":[{"name":"new","ai":"sw1","ap":"on"}]}`), 0644)
+	os.WriteFile(dir+"/schedules.json.bak", []byte(`{"version":2,"schedules":[{"name":"backup","ai":"sw1","ap":"on"}]}`), 0644)
+	os.WriteFile(dir+"/schedules.db", []byte("1;old;7;30;MTWTFSS;switch:sw1:on\n"), 0644)
+
+	ReadSchedulesFromFile()
+	if len(schedules) != 1 || schedules[0].name != "backup" {
+		t.Fatalf("got %+v, want the schedule of the backup", schedules)
+	}
+
+	// Without usable json the old schedules.db must not be migrated over the newer file
+	os.Remove(dir + "/schedules.json.bak")
+	schedules = []Schedule{}
+	ReadSchedulesFromFile()
+	if len(schedules) != 0 {
+		t.Fatalf("got %+v, want no schedules", schedules)
+	}
+	if _, err := os.Stat(dir + "/schedules.db"); err != nil {
+		t.Errorf("the schedules.db is renamed: %s", err)
+	}
+}
+
+func TestMigrateSchedulesOnce(t *testing.T) {
+	dir := setupScheduleStore(t)
+	os.WriteFile(dir+"/schedules.db", []byte("1;old;7;30;MTWTFss;switch:sw1:on\n"), 0644)
+
+	ReadSchedulesFromFile()
+	if len(schedules) != 1 || schedules[0].name != "old" || schedules[0].hour != 7 || !schedules[0].dayFri || schedules[0].daySat {
+		t.Fatalf("the schedules.db is not migrated: %+v", schedules)
+	}
+	if _, err := os.Stat(dir + "/schedules.json"); err != nil {
+		t.Fatalf("the schedules.json is not written: %s", err)
+	}
+	if _, err := os.Stat(dir + "/schedules.db"); err == nil {
+		t.Errorf("the schedules.db is not renamed")
+	}
+	if _, err := os.Stat(dir + "/schedules.db.migrated"); err != nil {
+		t.Errorf("the schedules.db.migrated is missing: %s", err)
+	}
+
+	// A new schedules.db appearing later is not migrated again, the json file is used
+	os.WriteFile(dir+"/schedules.db", []byte("1;other;8;0;MTWTFSS;switch:sw2:on\n"), 0644)
+	schedules = []Schedule{}
+	ReadSchedulesFromFile()
+	if len(schedules) != 1 || schedules[0].name != "old" {
+		t.Fatalf("got %+v, want the schedules of the json file", schedules)
+	}
+	if _, err := os.Stat(dir + "/schedules.db"); err != nil {
+		t.Errorf("the schedules.db is migrated again")
+	}
+}
@@ -147,8 +147,14 @@ func (p PanelScript) IsActionIdMatch(aId string) bool {
 }
 
 func (p *PanelScript) DoAction(actionName string, parameters map[string]string) (string, []string, bool) {
+	result, updatedIds, stateChanged, _ := p.DoDeviceAction(actionName, parameters)
+	return result, updatedIds, stateChanged
+}
+
+func (p *PanelScript) DoDeviceAction(actionName string, parameters map[string]string) (string, []string, bool, error) {
 	var stateChanged bool = false
 	var updatedIds []string = []string{}
+	var err error = nil
 
 	if actionName == "switch" {
 		actstr := "start"
@@ -160,6 +166,8 @@ func (p *PanelScript) DoAction(actionName string, parameters map[string]string)
 			stateChanged = true
 			time.Sleep(time.Millisecond * 500)
 			updatedIds = append(updatedIds, p.QueryDevice()...)
+		} else {
+			err = deviceOperationError(hr.err)
 		}
 	}
 
@@ -167,23 +175,28 @@ func (p *PanelScript) DoAction(actionName string, parameters map[string]string)
 		updatedIds = append(updatedIds, p.QueryDevice()...)
 	}
 
-	return "ok", updatedIds, stateChanged
+	return "ok", updatedIds, stateChanged, err
 }
 
-func (p *PanelScript) DoActionFromScheduler(actionName string) []string {
+func (p *PanelScript) DoActionFromScheduler(actionName string) ([]string, error) {
 
-	if actionName == "start" {
-		p.deviceHandler.ScriptTo(p, p.scriptName, "start", "scheduler")
-		time.Sleep(time.Millisecond * 500)
-		return p.QueryDevice()
-	}
-	if actionName == "stop" {
-		p.deviceHandler.ScriptTo(p, p.scriptName, "stop", "scheduler")
+	if actionName == "start" || actionName == "stop" {
+		r := p.deviceHandler.ScriptTo(p, p.scriptName, actionName, "scheduler")
 		time.Sleep(time.Millisecond * 500)
-		return p.QueryDevice()
+		if !r.ok {
+			return p.QueryDevice(), deviceOperationError(r.err)
+		}
+		return p.QueryDevice(), nil
 	}
 
-	return []string{}
+	return []string{}, errUnknownScheduledAction
+}
+
+func (p PanelScript) SchedulableActions() []SchedulableAction {
+	return []SchedulableAction{
+		{Name: "start", Title: "Start"},
+		{Name: "stop", Title: "Stop"},
+	}
 }
 
 func (p *PanelScript) QueryDevice() []string {
@@ -216,6 +229,7 @@ func (p *PanelScript) RefreshHwStatesInRequiredScriptPanels(State int) []string
 
 func (p *PanelScript) RefreshHwStateIfMatchScriptPanel(fromPanelType PanelTypes, fromDeviceIp string, fromInDeviceId int, fromScriptName string, State int) string {
 	if p.panelType == fromPanelType && p.deviceIp == fromDeviceIp && p.scriptName == fromScriptName {
+		AutomationPanelStateUpdate(p.idStr, p.hasValidInfo, p.state, State, p.inputState, 0)
 		p.state = State
 		p.inputState = 0
 		p.hasValidInfo = true
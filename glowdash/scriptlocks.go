@@ -0,0 +1,112 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Named lock held by a running program (Lock/Unlock commands).
+// The lock is reentrant within the same run, the nested Run/RunSet calls share the owner.
+type ScriptLock struct {
+	owner    *RunningScript
+	count    int
+	released chan struct{}
+}
+
+var scriptLocks map[string]*ScriptLock = map[string]*ScriptLock{}
+var scriptLocksMutex sync.Mutex
+
+var errScriptLockTimeout = errors.New("lock timeout")
+var errScriptLockStopped = errors.New("the program is stopped while waiting for the lock")
+
+// Acquires the named lock. Waits at most timeout (0 means no timeout), but not longer than the remaining
+// execution time of the program (ScriptMaxWallTime). Returns error if the lock is not acquired in time
+// or the program is stopped meanwhile.
+func acquireScriptLock(rs *RunningScript, name string, timeout time.Duration) error {
+	if ScriptMaxWallTime > 0 {
+		remaining := ScriptMaxWallTime - time.Since(rs.started)
+		if remaining <= 0 {
+			remaining = time.Millisecond
+		}
+		if timeout <= 0 || remaining < timeout {
+			timeout = remaining
+		}
+	}
+	var timeoutC <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutC = timer.C
+	}
+
+	for {
+		scriptLocksMutex.Lock()
+		sl, ok := scriptLocks[name]
+		if !ok {
+			scriptLocks[name] = &ScriptLock{owner: rs, count: 1, released: make(chan struct{})}
+			scriptLocksMutex.Unlock()
+			if DebugLevel > 2 {
+				fmt.Printf("Lock \"%s\" acquired by job %d\n", name, rs.id)
+			}
+			return nil
+		}
+		if sl.owner == rs {
+			sl.count++
+			scriptLocksMutex.Unlock()
+			return nil
+		}
+		released := sl.released
+		scriptLocksMutex.Unlock()
+
+		select {
+		case <-released:
+		case <-timeoutC:
+			return errScriptLockTimeout
+		case <-rs.cancel:
+			return errScriptLockStopped
+		}
+	}
+}
+
+// Releases the named lock, returns false if the lock is not held by the program
+func releaseScriptLock(rs *RunningScript, name string) bool {
+	scriptLocksMutex.Lock()
+	defer scriptLocksMutex.Unlock()
+	sl, ok := scriptLocks[name]
+	if !ok || sl.owner != rs {
+		return false
+	}
+	sl.count--
+	if sl.count <= 0 {
+		delete(scriptLocks, name)
+		close(sl.released)
+		if DebugLevel > 2 {
+			fmt.Printf("Lock \"%s\" released by job %d\n", name, rs.id)
+		}
+	}
+	return true
+}
+
+// Releases all locks held by the program, called when the program finished
+func releaseAllScriptLocks(rs *RunningScript) {
+	scriptLocksMutex.Lock()
+	defer scriptLocksMutex.Unlock()
+	for name, sl := range scriptLocks {
+		if sl.owner == rs {
+			delete(scriptLocks, name)
+			close(sl.released)
+			if DebugLevel > 2 {
+				fmt.Printf("Lock \"%s\" released at the end of job %d\n", name, rs.id)
+			}
+		}
+	}
+}
@@ -0,0 +1,180 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// One running (top level) program execution, the nested Run/RunSet calls share it
+type RunningScript struct {
+	id           int
+	name         string
+	started      time.Time
+	instructions int
+	stopReason   string
+	cancel       chan struct{}
+}
+
+type RunningScriptInfo struct {
+	Id           int
+	Name         string
+	Started      time.Time
+	Elapsed      time.Duration
+	Instructions int
+}
+
+var ScriptMaxInstructions int = 1000000
+var ScriptMaxWallTime time.Duration = time.Duration(600) * time.Second
+var ScriptMaxNesting int = 16
+
+var runningScripts map[int]*RunningScript = map[int]*RunningScript{}
+var runningScriptsMutex sync.Mutex
+var runningScriptsNextId int = 1
+
+func registerRunningScript(name string) *RunningScript {
+	runningScriptsMutex.Lock()
+	defer runningScriptsMutex.Unlock()
+	rs := &RunningScript{
+		id:           runningScriptsNextId,
+		name:         name,
+		started:      time.Now(),
+		instructions: 0,
+		stopReason:   "",
+		cancel:       make(chan struct{}),
+	}
+	runningScriptsNextId++
+	runningScripts[rs.id] = rs
+	return rs
+}
+
+func unregisterRunningScript(rs *RunningScript) {
+	releaseAllScriptLocks(rs)
+	runningScriptsMutex.Lock()
+	defer runningScriptsMutex.Unlock()
+	delete(runningScripts, rs.id)
+}
+
+// Stops the running script at the next command or interrupts its waiting
+func stopRunningScript(rs *RunningScript, reason string) {
+	runningScriptsMutex.Lock()
+	defer runningScriptsMutex.Unlock()
+	if rs.stopReason != "" {
+		return
+	}
+	rs.stopReason = reason
+	close(rs.cancel)
+}
+
+func killRunningScript(id int) bool {
+	runningScriptsMutex.Lock()
+	rs, ok := runningScripts[id]
+	runningScriptsMutex.Unlock()
+	if !ok {
+		return false
+	}
+	stopRunningScript(rs, "Killed by user")
+	GlowdashConsole.Write(T("Program \"{{program}}\" killed", map[string]any{"program": rs.name}))
+	return true
+}
+
+func listRunningScripts() []RunningScriptInfo {
+	runningScriptsMutex.Lock()
+	defer runningScriptsMutex.Unlock()
+	list := []RunningScriptInfo{}
+	for _, rs := range runningScripts {
+		list = append(list, RunningScriptInfo{
+			Id:           rs.id,
+			Name:         rs.name,
+			Started:      rs.started,
+			Elapsed:      time.Since(rs.started),
+			Instructions: rs.instructions,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Id < list[j].Id })
+	return list
+}
+
+// Counts the executed command and checks the limits.
+// Returns the reason if the execution have to be stopped, otherwise empty string.
+func (rs *RunningScript) step() string {
+	runningScriptsMutex.Lock()
+	rs.instructions++
+	reason := rs.stopReason
+	instructions := rs.instructions
+	runningScriptsMutex.Unlock()
+
+	if reason != "" {
+		return reason
+	}
+	if ScriptMaxInstructions > 0 && instructions > ScriptMaxInstructions {
+		reason = fmt.Sprintf("Instruction limit (%d) exceeded", ScriptMaxInstructions)
+	}
+	if ScriptMaxWallTime > 0 && time.Since(rs.started) > ScriptMaxWallTime {
+		reason = fmt.Sprintf("Execution time limit (%s) exceeded", ScriptMaxWallTime)
+	}
+	if reason != "" {
+		stopRunningScript(rs, reason)
+	}
+	return reason
+}
+
+// Waits the given time, returns earlier if the script is stopped or reaches the time limit
+func (rs *RunningScript) wait(d time.Duration) {
+	if ScriptMaxWallTime > 0 {
+		remaining := ScriptMaxWallTime - time.Since(rs.started)
+		if remaining < d {
+			d = remaining
+		}
+	}
+	if d <= 0 {
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-rs.cancel:
+	}
+}
+
+func isRunningScript(id int) bool {
+	runningScriptsMutex.Lock()
+	defer runningScriptsMutex.Unlock()
+	_, ok := runningScripts[id]
+	return ok
+}
+
+// Starts the program in a new goroutine and returns its id in the running script registry.
+// The panels collected by RelatedPanel commands are refreshed through SSE when the program finished.
+func startBackgroundProgram(code string, variables map[string]string, options ProgramRunOptions,
+	finished func(results map[string]string, updatedIds []string) []string) int {
+	options.run = registerRunningScript(options.Name)
+	if DebugLevel > 2 {
+		fmt.Printf("Start background program \"%s\" (job %d)\n", options.Name, options.run.id)
+	}
+	go func() {
+		defer unregisterRunningScript(options.run)
+		relatedPanels := []string{}
+		results := ExecuteCommandsWithOptions(code, variables, &relatedPanels, options)
+		updatedIds := getUpdatedIdsFromRelatedPanels(relatedPanels)
+		if finished != nil {
+			updatedIds = finished(results, updatedIds)
+		}
+		if len(updatedIds) > 0 {
+			panelUpdateRequestSSE(updatedIds)
+		}
+		if DebugLevel > 2 {
+			fmt.Printf("Background program \"%s\" (job %d) finished\n", options.Name, options.run.id)
+		}
+	}()
+	return options.run.id
+}
@@ -0,0 +1,121 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+
+	"github.com/hyper-prog/smartyaml"
+)
+
+type PageScriptTest struct {
+	PageBase
+}
+
+func NewPageScriptTest() *PageScriptTest {
+	return &PageScriptTest{
+		PageBase{
+			idStr:      "",
+			pageType:   ScriptTest,
+			title:      "",
+			deviceType: "",
+			index:      0,
+		},
+	}
+}
+
+func (p *PageScriptTest) LoadCustomConfig(sy smartyaml.SmartYAML, indexInConfig int) {
+	if p.title == "" {
+		p.title = T("Test program")
+	}
+}
+
+func (p PageScriptTest) PageHtml(withContainer bool, r *http.Request) string {
+	program := r.Form.Get("program")
+	variables := r.Form.Get("variables")
+	mocks := r.Form.Get("mocks")
+
+	names := libraryProgramNames()
+
+	h := "<div class=\"schedule-edit-page\">"
+	h += "<h3>" + p.title + "</h3>"
+	h += "<form method=\"post\" enctype=\"application/x-www-form-urlencoded\">"
+	h += "<p class=\"whitetext\">" + T("Program") + "</p>"
+	h += "<select name=\"program\">"
+	for _, name := range names {
+		h += "<option value=\"" + html.EscapeString(name) + "\"" + IfTrue(name == program, " selected") + ">" +
+			html.EscapeString(name) + "</option>"
+	}
+	h += "</select>"
+	h += "<p class=\"whitetext\">" + T("Input variables (name=value, one per line)") + "</p>"
+	h += "<textarea name=\"variables\" class=\"scripttest-input\" rows=\"5\">" + html.EscapeString(variables) + "</textarea>"
+	h += "<p class=\"whitetext\">" + T("Mock values (target = value, one per line)") + "</p>"
+	h += "<textarea name=\"mocks\" class=\"scripttest-input\" rows=\"5\">" + html.EscapeString(mocks) + "</textarea>"
+	h += "<br/><input type=\"submit\" name=\"sttsubmit\" value=\"" + T("Run test") + "\" class=\"schedule-submit-button\" />"
+	h += "</form>"
+	if LibraryDirectory != "" {
+		h += "<button id=\"act-library-reload\" class=\"jsaction scheduleedit-ctrl-button\">" + T("Reload library") + "</button>"
+	}
+
+	if r.Form.Get("sttsubmit") == T("Run test") {
+		h += htmlScriptTestResult(program, variables, mocks)
+	}
+	h += "</div>"
+
+	if withContainer {
+		return fmt.Sprintf("<div id=\"pc-%s\" class=\"fullpage-content\" tabindex=\"-1\">", p.IdStr()) +
+			h + "</div>"
+	}
+
+	return h
+}
+
+func htmlScriptTestResult(program string, variables string, mocks string) string {
+	code, found := getLibraryProgram(program)
+	if !found {
+		return "<p class=\"whitetext\">" + T("Unknown program") + "</p>"
+	}
+
+	dr := NewDryRun(ParseDryRunMocks(mocks))
+	results := ExecuteCommandsDryRun(code, ParseDryRunVariables(variables), LibraryProgramRunOptions(program), dr)
+
+	h := "<h3>" + T("Result") + "</h3>"
+	h += "<table class=\"stattable\">"
+	h += "<tr class=\"normcolor\"><td>" + T("Return value") + "</td><td>" + html.EscapeString(results["Return"]) + "</td></tr>"
+	if results["Error"] != "" {
+		h += "<tr class=\"altcolor\"><td>" + T("Error") + "</td><td class=\"csred\">" + html.EscapeString(results["Error"]) + "</td></tr>"
+	}
+	h += "</table>"
+
+	h += "<h3>" + T("Trace") + "</h3>"
+	trace := dr.Trace()
+	if len(trace) == 0 {
+		return h + "<p class=\"whitetext\">" + T("Nothing was recorded.") + "</p>"
+	}
+	h += "<table class=\"stattable\">"
+	for i, line := range trace {
+		h += "<tr class=\"" + IfTrue(i%2 == 0, "normcolor") + IfTrue(i%2 == 1, "altcolor") + "\">"
+		h += "<td>" + html.EscapeString(line) + "</td></tr>"
+	}
+	h += "</table>"
+	return h
+}
+
+func (p PageScriptTest) IsActionIdMatch(aId string) bool {
+	return aId == "act-library-reload"
+}
+
+func (p PageScriptTest) HandleActionEvent(res *ActionResponse, actionName string, parameters map[string]string) {
+	if actionName == "act-library-reload" {
+		ReloadLibrary()
+		res.addCommandArg0("refreshpage")
+		res.setResultString("ok")
+	}
+}
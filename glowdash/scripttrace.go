@@ -0,0 +1,223 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ScriptTraceEntry struct {
+	offset      time.Duration
+	program     string
+	line        int
+	kind        string
+	text        string
+	duration    time.Duration
+	hasDuration bool
+}
+
+// Step by step record of one program execution, the nested Run/RunSet calls are recorded into the caller's trace
+type ScriptTrace struct {
+	id        int
+	name      string
+	started   time.Time
+	finished  time.Time
+	running   bool
+	result    string
+	entries   []ScriptTraceEntry
+	truncated bool
+	lastLine  int
+	mutex     sync.Mutex
+}
+
+var MaxScriptTraces int = 20
+var MaxScriptTraceEntries int = 5000
+var ProgramLibraryTrace = map[string]bool{}
+
+var scriptTraces []*ScriptTrace = []*ScriptTrace{}
+var scriptTracesMutex sync.Mutex
+var scriptTracesNextId int = 1
+
+// The commands which are marked as device calls in the trace
+var traceDeviceCommands = []string{"ShellyRelay ", "ModbusTcp ", "CallHttp ", "CallHttpStoreJson ", "CallHttpEx ", "SetFromJsonReq ", "Panel "}
+
+// Starts a new trace and puts it into the list of last traces (the oldest is dropped)
+func startScriptTrace(name string) *ScriptTrace {
+	scriptTracesMutex.Lock()
+	defer scriptTracesMutex.Unlock()
+	st := &ScriptTrace{
+		id:       scriptTracesNextId,
+		name:     name,
+		started:  time.Now(),
+		running:  true,
+		entries:  []ScriptTraceEntry{},
+		lastLine: -1,
+	}
+	scriptTracesNextId++
+	scriptTraces = append(scriptTraces, st)
+	if MaxScriptTraces > 0 && len(scriptTraces) > MaxScriptTraces {
+		scriptTraces = scriptTraces[len(scriptTraces)-MaxScriptTraces:]
+	}
+	return st
+}
+
+func (st *ScriptTrace) finish(result string) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	st.closeLastLine()
+	st.finished = time.Now()
+	st.running = false
+	st.result = result
+}
+
+// Sets the duration of the previous line, it lasts until the next line is started. Requires the lock.
+func (st *ScriptTrace) closeLastLine() {
+	if st.lastLine >= 0 {
+		st.entries[st.lastLine].duration = time.Since(st.started) - st.entries[st.lastLine].offset
+		st.entries[st.lastLine].hasDuration = true
+		st.lastLine = -1
+	}
+}
+
+func (st *ScriptTrace) add(ctx *RunContext, kind string, text string) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	if kind == "line" || kind == "device" || kind == "cond" {
+		st.closeLastLine()
+	}
+	if MaxScriptTraceEntries > 0 && len(st.entries) >= MaxScriptTraceEntries {
+		st.truncated = true
+		return
+	}
+	st.entries = append(st.entries, ScriptTraceEntry{
+		offset:  time.Since(st.started),
+		program: ctx.options.Name,
+		line:    ctx.ip + 1,
+		kind:    kind,
+		text:    text,
+	})
+	if kind == "line" || kind == "device" {
+		st.lastLine = len(st.entries) - 1
+	}
+}
+
+// Records an executed line
+func traceLine(ctx *RunContext, cmd string) {
+	if ctx.options.trace == nil {
+		return
+	}
+	kind := "line"
+	for _, dc := range traceDeviceCommands {
+		if strings.HasPrefix(cmd, dc) {
+			kind = "device"
+			break
+		}
+	}
+	ctx.options.trace.add(ctx, kind, cmd)
+}
+
+// Records an evaluated If/While condition
+func traceCondition(ctx *RunContext, command string, condition string, result bool) {
+	if ctx.options.trace == nil {
+		return
+	}
+	ctx.options.trace.add(ctx, "cond", fmt.Sprintf("%s %s => %t", command, ResolveVariables(*ctx, condition), result))
+}
+
+func traceVariable(ctx *RunContext, name string, value string) {
+	if ctx.options.trace == nil {
+		return
+	}
+	ctx.options.trace.add(ctx, "set", name+" = "+value)
+}
+
+func traceError(ctx *RunContext, message string) {
+	if ctx.options.trace == nil {
+		return
+	}
+	ctx.options.trace.add(ctx, "error", message)
+}
+
+type ScriptTraceInfo struct {
+	Id        int
+	Name      string
+	Started   time.Time
+	Duration  time.Duration
+	Running   bool
+	Result    string
+	Entries   int
+	Truncated bool
+}
+
+func (st *ScriptTrace) info() ScriptTraceInfo {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	duration := st.finished.Sub(st.started)
+	if st.running {
+		duration = time.Since(st.started)
+	}
+	return ScriptTraceInfo{
+		Id:        st.id,
+		Name:      st.name,
+		Started:   st.started,
+		Duration:  duration,
+		Running:   st.running,
+		Result:    st.result,
+		Entries:   len(st.entries),
+		Truncated: st.truncated,
+	}
+}
+
+// Returns the list of the stored traces, the newest first
+func listScriptTraces() []ScriptTraceInfo {
+	scriptTracesMutex.Lock()
+	traces := append([]*ScriptTrace{}, scriptTraces...)
+	scriptTracesMutex.Unlock()
+
+	list := []ScriptTraceInfo{}
+	for i := len(traces) - 1; i >= 0; i-- {
+		list = append(list, traces[i].info())
+	}
+	return list
+}
+
+func getScriptTrace(id int) *ScriptTrace {
+	scriptTracesMutex.Lock()
+	defer scriptTracesMutex.Unlock()
+	for _, st := range scriptTraces {
+		if st.id == id {
+			return st
+		}
+	}
+	return nil
+}
+
+// Returns the copy of the entries
+func (st *ScriptTrace) getEntries() []ScriptTraceEntry {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	return append([]ScriptTraceEntry{}, st.entries...)
+}
+
+// Removes the finished traces
+func clearScriptTraces() {
+	scriptTracesMutex.Lock()
+	defer scriptTracesMutex.Unlock()
+	kept := []*ScriptTrace{}
+	for _, st := range scriptTraces {
+		st.mutex.Lock()
+		if st.running {
+			kept = append(kept, st)
+		}
+		st.mutex.Unlock()
+	}
+	scriptTraces = kept
+}
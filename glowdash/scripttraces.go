@@ -0,0 +1,159 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyper-prog/smartyaml"
+)
+
+type PageScriptTraces struct {
+	PageBase
+}
+
+func NewPageScriptTraces() *PageScriptTraces {
+	return &PageScriptTraces{
+		PageBase{
+			idStr:      "",
+			pageType:   ScriptTraces,
+			title:      "",
+			deviceType: "",
+			index:      0,
+		},
+	}
+}
+
+func (p *PageScriptTraces) LoadCustomConfig(sy smartyaml.SmartYAML, indexInConfig int) {
+	if p.title == "" {
+		p.title = T("Program traces")
+	}
+}
+
+func (p PageScriptTraces) PageHtml(withContainer bool, r *http.Request) string {
+	html := "<div class=\"schedule-edit-page\">"
+	html += "<h3>" + p.title + "</h3>"
+	html += htmlScriptTracesTable()
+	html += "<button id=\"act-traces-refresh\" class=\"jsaction scheduleedit-ctrl-button\">" + T("Refresh") + "</button>"
+	html += "<button id=\"act-traces-clear\" class=\"jsaction scheduleedit-ctrl-button\">" + T("Clear") + "</button>"
+	html += "<div id=\"scripttrace-detail\"></div>"
+	html += "</div>"
+
+	if withContainer {
+		return fmt.Sprintf("<div id=\"pc-%s\" class=\"fullpage-content\" tabindex=\"-1\">", p.IdStr()) +
+			html + "</div>"
+	}
+
+	return html
+}
+
+func htmlScriptTracesTable() string {
+	list := listScriptTraces()
+	if len(list) == 0 {
+		return "<p class=\"whitetext\">" + T("There is no recorded trace. Set Trace: yes on the program to record it.") + "</p>"
+	}
+
+	h := "<table class=\"stattable\">"
+	h += "<tr><th>" + T("Num") +
+		"</th><th>" + T("Program") +
+		"</th><th>" + T("Started") +
+		"</th><th>" + T("Elapsed") +
+		"</th><th>" + T("Steps") +
+		"</th><th>" + T("Result") +
+		"</th><th></th></tr>"
+	for i, ti := range list {
+		name := ti.Name
+		if name == "" {
+			name = "-"
+		}
+		result := ti.Result
+		if ti.Running {
+			result = T("Running...")
+		}
+		h += "<tr class=\"" + IfTrue(i%2 == 0, "normcolor") + IfTrue(i%2 == 1, "altcolor") + "\">"
+		h += "<td>" + fmt.Sprintf("%d", ti.Id) + "</td>"
+		h += "<td>" + html.EscapeString(name) + "</td>"
+		h += "<td>" + ti.Started.In(TimeLocation).Format("2006-01-02 15:04:05") + "</td>"
+		h += "<td>" + ti.Duration.Round(time.Millisecond).String() + "</td>"
+		h += "<td>" + fmt.Sprintf("%d", ti.Entries) + IfTrue(ti.Truncated, "+") + "</td>"
+		h += "<td" + IfTrue(!ti.Running && ti.Result != "ok", " class=\"csred\"") + ">" + html.EscapeString(result) + "</td>"
+		h += "<td><button class=\"jsaction scheduleedit-ctrl-button\" id=\"act-trace-show-" + fmt.Sprintf("%d", ti.Id) + "\">" +
+			T("Show") + "</button></td>"
+		h += "</tr>"
+	}
+	h += "</table>"
+	return h
+}
+
+func htmlScriptTraceDetail(st *ScriptTrace) string {
+	ti := st.info()
+	h := "<h3>" + html.EscapeString(ti.Name) + " (" + fmt.Sprintf("%d", ti.Id) + ")</h3>"
+	h += "<table class=\"stattable\">"
+	h += "<tr><th>" + T("Time") +
+		"</th><th>" + T("Line") +
+		"</th><th>" + T("Type") +
+		"</th><th>" + T("Command") +
+		"</th><th>" + T("Duration") +
+		"</th></tr>"
+	for i, e := range st.getEntries() {
+		h += "<tr class=\"" + IfTrue(i%2 == 0, "normcolor") + IfTrue(i%2 == 1, "altcolor") + "\">"
+		h += "<td>+" + fmt.Sprintf("%d", e.offset.Milliseconds()) + "ms</td>"
+		h += "<td>" + html.EscapeString(e.program) + ":" + fmt.Sprintf("%d", e.line) + "</td>"
+		h += "<td" + IfTrue(e.kind == "error", " class=\"csred\"") + IfTrue(e.kind == "device", " class=\"csgreen\"") + ">" +
+			e.kind + "</td>"
+		h += "<td>" + html.EscapeString(e.text) + "</td>"
+		h += "<td>"
+		if e.hasDuration {
+			h += e.duration.Round(time.Microsecond).String()
+		}
+		h += "</td>"
+		h += "</tr>"
+	}
+	h += "</table>"
+	if ti.Truncated {
+		h += "<p class=\"whitetext\">" + T("The trace is truncated.") + "</p>"
+	}
+	return h
+}
+
+func (p PageScriptTraces) IsActionIdMatch(aId string) bool {
+	if aId == "act-traces-refresh" || aId == "act-traces-clear" {
+		return true
+	}
+	if strings.HasPrefix(aId, "act-trace-show-") {
+		return true
+	}
+	return false
+}
+
+func (p PageScriptTraces) HandleActionEvent(res *ActionResponse, actionName string, parameters map[string]string) {
+	if actionName == "act-traces-refresh" {
+		res.addCommandArg0("refreshpage")
+		res.setResultString("ok")
+	}
+	if actionName == "act-traces-clear" {
+		clearScriptTraces()
+		res.addCommandArg0("refreshpage")
+		res.setResultString("ok")
+	}
+	if strings.HasPrefix(actionName, "act-trace-show-") {
+		id, err := strconv.Atoi(actionName[15:])
+		if err == nil {
+			st := getScriptTrace(id)
+			if st != nil {
+				res.addCommandArg2("sethtml", "#scripttrace-detail", htmlScriptTraceDetail(st))
+			}
+		}
+		res.setResultString("ok")
+	}
+}
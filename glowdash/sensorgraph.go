@@ -101,7 +101,7 @@ func (p PageSensorGraph) CollectSensorHistory_smtherm(length int, offset int, te
 		j := execJsonTcpQuery(p.hwDeviceIp, p.hwDevicePort, fmt.Sprintf("cmd:qhis;sn:%s;off:%d;len:%d;", p.sensors[i].codename, offset, length))
 		if j.Success {
 			starttimeunix = int64(j.SmartJSON.GetFloat64ByPathWithDefault("/st", 0))
-			starttime = time.Unix(starttimeunix, 0)
+			starttime = time.Unix(starttimeunix, 0).In(TimeLocation)
 
 			arr, _ := j.SmartJSON.GetArrayByPath("$.d")
 			alen := len(arr)
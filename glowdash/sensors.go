@@ -21,6 +21,9 @@ type SensorData struct {
 	codename string
 	temp     float32
 	hum      float32
+
+	// The temp and hum are measured values (the threshold automations compare the new value with them)
+	measured bool
 }
 
 type PanelSensors struct {
@@ -159,6 +162,9 @@ func (p *PanelSensors) RefreshHwStateIfMatch(fromPanelType PanelTypes, fromDevic
 
 func (p *PanelSensors) InvalidateInfo() {
 	p.hasValidInfo = false
+	for i := range p.sensors {
+		p.sensors[i].measured = false
+	}
 }
 
 func (p PanelSensors) IsActionIdMatch(aId string) bool {
@@ -194,7 +200,7 @@ func (p PanelSensors) QueryDevice() []string {
 				temp := j.SmartJSON.GetFloat64ByPathWithDefault(fmt.Sprintf("/sensors/[%d]/temp", i), -100.0)
 				hum := j.SmartJSON.GetFloat64ByPathWithDefault(fmt.Sprintf("/sensors/[%d]/hum", i), -100.0)
 				if len(name) > 0 && temp > -100 && hum > -100 {
-					sensors = append(sensors, SensorData{"", name, float32(temp), float32(hum)})
+					sensors = append(sensors, SensorData{"", name, float32(temp), float32(hum), true})
 				}
 			}
 			updatedIds = append(updatedIds, p.RefreshHwStatesInRequiredPanelsSensors(sensors)...)
@@ -231,8 +237,11 @@ func (p *PanelSensors) RefreshHwStateIfMatchSensors(fromPanelType PanelTypes, fr
 		for _, s := range sensors {
 			for i := 0; i < c; i++ {
 				if p.sensors[i].codename == s.codename {
+					AutomationSensorUpdate(p.idStr, s.codename, "Temp", p.sensors[i].measured, float64(p.sensors[i].temp), float64(s.temp))
+					AutomationSensorUpdate(p.idStr, s.codename, "Hum", p.sensors[i].measured, float64(p.sensors[i].hum), float64(s.hum))
 					p.sensors[i].temp = s.temp
 					p.sensors[i].hum = s.hum
+					p.sensors[i].measured = true
 					p.hasValidInfo = true
 					break
 				}
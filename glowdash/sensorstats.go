@@ -200,7 +200,7 @@ func (p PageSensorStats) CollectHeaterHistory_smtherm() ([]string, []string, []s
 				f1, isFloat1 := subarr[1].(float64)
 
 				if isFloat0 && isFloat1 {
-					tm = time.Unix(int64(f0), 0)
+					tm = time.Unix(int64(f0), 0).In(TimeLocation)
 					when = append(when, tm.Format("2006-01-02 15:04:05"))
 
 					whatstr := "unknown"
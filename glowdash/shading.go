@@ -247,23 +247,38 @@ func (p *PanelShading) WaitUntilStateIsMoving(waitMillisec int, maxWaitMillisec
 }
 
 func (p *PanelShading) DoAction(actionName string, parameters map[string]string) (string, []string, bool) {
+	result, updatedIds, stateChanged, _ := p.DoDeviceAction(actionName, parameters)
+	return result, updatedIds, stateChanged
+}
+
+func (p *PanelShading) DoDeviceAction(actionName string, parameters map[string]string) (string, []string, bool, error) {
 	var stateChanged bool = false
 	var updatedIds []string = []string{}
+	var err error = nil
 
 	if actionName == "up" {
 		r := p.deviceHandler.PerformThis(p, "up", "action")
+		if !r.ok {
+			err = deviceOperationError(r.err)
+		}
 		stateChanged = true
 		updatedIds = r.updIds
 		updatedIds = append(updatedIds, p.QueryDevice()...)
 	}
 	if actionName == "down" {
 		r := p.deviceHandler.PerformThis(p, "down", "action")
+		if !r.ok {
+			err = deviceOperationError(r.err)
+		}
 		stateChanged = true
 		updatedIds = r.updIds
 		updatedIds = append(updatedIds, p.QueryDevice()...)
 	}
 	if actionName == "stop" {
 		r := p.deviceHandler.PerformThis(p, "stop", "action")
+		if !r.ok {
+			err = deviceOperationError(r.err)
+		}
 		stateChanged = true
 		updatedIds = r.updIds
 		updatedIds = append(updatedIds, p.QueryDevice()...)
@@ -276,19 +291,29 @@ func (p *PanelShading) DoAction(actionName string, parameters map[string]string)
 		updatedIds = append(updatedIds, p.QueryDevice()...)
 	}
 
-	return "ok", updatedIds, stateChanged
+	return "ok", updatedIds, stateChanged, err
 }
 
-func (p *PanelShading) DoActionFromScheduler(actionName string) []string {
-	if actionName == "open" {
-		p.deviceHandler.PerformThis(p, "up", "scheduler")
-		return p.QueryDevice()
+func (p *PanelShading) DoActionFromScheduler(actionName string) ([]string, error) {
+	if actionName == "open" || actionName == "close" {
+		fnc := "up"
+		if actionName == "close" {
+			fnc = "down"
+		}
+		r := p.deviceHandler.PerformThis(p, fnc, "scheduler")
+		if !r.ok {
+			return p.QueryDevice(), deviceOperationError(r.err)
+		}
+		return p.QueryDevice(), nil
 	}
-	if actionName == "close" {
-		p.deviceHandler.PerformThis(p, "down", "scheduler")
-		return p.QueryDevice()
+	return []string{}, errUnknownScheduledAction
+}
+
+func (p PanelShading) SchedulableActions() []SchedulableAction {
+	return []SchedulableAction{
+		{Name: "open", Title: "Open"},
+		{Name: "close", Title: "Close"},
 	}
-	return []string{}
 }
 
 func (p *PanelShading) QueryDevice() []string {
@@ -323,6 +348,7 @@ func (p *PanelShading) RefreshHwStatesInRequiredPanelsCover(State int, coverName
 
 func (p *PanelShading) RefreshHwStateIfMatchCover(fromPanelType PanelTypes, fromDeviceIp string, fromInDeviceId int, fromScriptName string, State int, coverNamedState string, PowMet bool, Watt float64, Volt float64) string {
 	if p.panelType == fromPanelType && p.deviceIp == fromDeviceIp && p.inDeviceId == fromInDeviceId {
+		AutomationPanelStateUpdate(p.idStr, p.hasValidInfo, p.state, State, 0, 0)
 		p.state = State
 		p.coverNamedState = coverNamedState
 		p.hasValidInfo = true
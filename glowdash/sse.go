@@ -0,0 +1,156 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The built-in SSE server (WebUseSSE: 2). The browsers connect to the /sse endpoint of GlowDash.
+// The messages have the format of the Hasses control channel: "<topic>=<message>" is sent to
+// all subscribers of the topic, "<topic>-<id>=<message>" to all of them except the client with the id.
+type sseClient struct {
+	id       string
+	topics   map[string]bool
+	messages chan string
+}
+
+// The number of messages waiting for a slow client, the further messages are dropped
+const sseClientBufferSize int = 32
+
+var SSEHeartbeatInterval int = 25
+var SSEControlPort int = 0
+var SSEControlHost string = "127.0.0.1"
+
+var sseClients map[*sseClient]bool = map[*sseClient]bool{}
+var sseMutex sync.Mutex
+
+func BuiltinSSEEnabled() bool {
+	return WebUseSSE == 2
+}
+
+// Splits the "<topic>[-<excludedid>]=<message>" notify message
+func parseSSENotify(notify string) (string, string, string, bool) {
+	target, message, found := strings.Cut(strings.TrimSpace(notify), "=")
+	if !found || target == "" {
+		return "", "", "", false
+	}
+	topic, excluded, _ := strings.Cut(target, "-")
+	return topic, excluded, message, true
+}
+
+// Sends the notify message to the subscribed clients of the built-in SSE server
+func ssePublish(notify string) {
+	topic, excluded, message, ok := parseSSENotify(notify)
+	if !ok {
+		return
+	}
+	sseMutex.Lock()
+	defer sseMutex.Unlock()
+	for c := range sseClients {
+		if !c.topics[topic] || (excluded != "" && c.id == excluded) {
+			continue
+		}
+		select {
+		case c.messages <- message:
+		default:
+			if DebugLevel > 1 {
+				fmt.Printf("SSE client %s is slow, message dropped\n", c.id)
+			}
+		}
+	}
+}
+
+// The /sse?id=<clientid>&subscribe=<topic1>-<topic2>... endpoint
+func handleSSE(w http.ResponseWriter, r *http.Request) {
+	if !BuiltinSSEEnabled() {
+		http.Error(w, "404 Not Found", 404)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "500 Internal Server Error", 500)
+		return
+	}
+
+	c := &sseClient{id: r.URL.Query().Get("id"), topics: map[string]bool{}, messages: make(chan string, sseClientBufferSize)}
+	for _, topic := range strings.Split(r.URL.Query().Get("subscribe"), "-") {
+		if topic != "" {
+			c.topics[topic] = true
+		}
+	}
+	sseMutex.Lock()
+	sseClients[c] = true
+	sseMutex.Unlock()
+	defer func() {
+		sseMutex.Lock()
+		delete(sseClients, c)
+		sseMutex.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	fmt.Fprint(w, "retry: 5000\ndata: Hello\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(time.Duration(SSEHeartbeatInterval) * time.Second)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case message := <-c.messages:
+			for _, line := range strings.Split(message, "\n") {
+				fmt.Fprintf(w, "data: %s\n", line)
+			}
+			fmt.Fprint(w, "\n")
+			flusher.Flush()
+		case <-heartbeat.C:
+			// Comment line, keeps the connection alive through the proxies
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// Receives the notify messages of other programs (e.g. SMTherm) in the format of the Hasses
+// control channel, so they can use the built-in SSE server instead of Hasses. One message per line.
+// The channel has no authentication, it listens only on the loopback interface unless the SSEControlHost is set.
+func sseControlListener() {
+	listener, err := net.Listen("tcp", net.JoinHostPort(SSEControlHost, strconv.Itoa(SSEControlPort)))
+	if err != nil {
+		fmt.Printf("Cannot start SSE control listener: %s\n", err)
+		return
+	}
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			continue
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				if DebugLevel > 1 {
+					fmt.Printf("SSE control message: %s\n", scanner.Text())
+				}
+				ssePublish(scanner.Text())
+			}
+		}(conn)
+	}
+}
@@ -0,0 +1,137 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// The state. variables (stored without the state. prefix) shared by all running programs.
+// Always use the accessor functions below, the programs run in parallel goroutines.
+var GlowdashStateVariables = map[string]string{}
+var stateVariablesMutex sync.RWMutex
+var stateVariablesSaveMutex sync.Mutex
+
+// The state. variables named here (without the state. prefix, trailing * matches any suffix) are saved
+// to the StateConfigDirectory. The variables starting with persist. are always saved.
+var PersistentStateVariables []string = []string{}
+var stateVariablesUnsaved bool = false
+
+func isPersistentStateVariable(name string) bool {
+	if strings.HasPrefix(name, "persist.") {
+		return true
+	}
+	for _, p := range PersistentStateVariables {
+		if strings.HasSuffix(p, "*") {
+			if strings.HasPrefix(name, p[:len(p)-1]) {
+				return true
+			}
+			continue
+		}
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+func getStateVariable(name string) (string, bool) {
+	stateVariablesMutex.RLock()
+	defer stateVariablesMutex.RUnlock()
+	v, ok := GlowdashStateVariables[name]
+	return v, ok
+}
+
+// Sets the state variable and returns its previous value
+func setStateVariable(name string, value string) string {
+	stateVariablesMutex.Lock()
+	defer stateVariablesMutex.Unlock()
+	oldValue := GlowdashStateVariables[name]
+	GlowdashStateVariables[name] = value
+	if oldValue != value && isPersistentStateVariable(name) {
+		stateVariablesUnsaved = true
+	}
+	return oldValue
+}
+
+// Returns a copy of all state variables
+func stateVariablesSnapshot() map[string]string {
+	stateVariablesMutex.RLock()
+	defer stateVariablesMutex.RUnlock()
+	snapshot := make(map[string]string, len(GlowdashStateVariables))
+	for n, v := range GlowdashStateVariables {
+		snapshot[n] = v
+	}
+	return snapshot
+}
+
+func SaveStateVariablesToFile() {
+	stateVariablesSaveMutex.Lock()
+	defer stateVariablesSaveMutex.Unlock()
+
+	persistent := map[string]string{}
+	stateVariablesMutex.Lock()
+	for n, v := range GlowdashStateVariables {
+		if isPersistentStateVariable(n) {
+			persistent[n] = v
+		}
+	}
+	stateVariablesUnsaved = false
+	stateVariablesMutex.Unlock()
+
+	content, err := json.MarshalIndent(persistent, "", "  ")
+	if err != nil {
+		fmt.Println("Cannot encode state variables")
+		return
+	}
+
+	if DebugLevel > 0 {
+		fmt.Println("Writing statevariables.json")
+	}
+
+	err = writeFileAtomic(StateConfigDirectory+"/statevariables.json", content)
+	if err != nil {
+		fmt.Printf("Cannot write statevariables.json: %s\n", err)
+		stateVariablesMutex.Lock()
+		stateVariablesUnsaved = true
+		stateVariablesMutex.Unlock()
+	}
+}
+
+func ReadStateVariablesFromFile() {
+	content, err := os.ReadFile(StateConfigDirectory + "/statevariables.json")
+	if err != nil {
+		return
+	}
+	persistent := map[string]string{}
+	if err = json.Unmarshal(content, &persistent); err != nil {
+		fmt.Printf("Cannot parse statevariables.json: %s\n", err)
+		return
+	}
+	stateVariablesMutex.Lock()
+	defer stateVariablesMutex.Unlock()
+	for n, v := range persistent {
+		if isPersistentStateVariable(n) {
+			GlowdashStateVariables[n] = v
+		}
+	}
+	stateVariablesUnsaved = false
+}
+
+func SaveStateVariablesIfRequired() {
+	stateVariablesMutex.RLock()
+	unsaved := stateVariablesUnsaved
+	stateVariablesMutex.RUnlock()
+	if unsaved {
+		SaveStateVariablesToFile()
+	}
+}
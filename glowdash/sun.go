@@ -0,0 +1,196 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The geographic location of the home, required by the sun calculations
+var LocationSet bool = false
+var LocationLatitude float64 = 0.0
+var LocationLongitude float64 = 0.0
+
+// The zenith angles of the sun events: the sunrise/sunset is corrected by the refraction and the sun radius
+const sunZenithSunrise float64 = 90.833
+const sunZenithCivil float64 = 96.0
+
+// The sun events usable as time reference in the schedules
+var SunEvents = []string{"sunrise", "sunset", "dawn", "dusk"}
+
+var sunEventDisplayText = map[string]string{
+	"sunrise": "Sunrise",
+	"sunset":  "Sunset",
+	"dawn":    "Civil dawn",
+	"dusk":    "Civil dusk",
+}
+
+func degToRad(d float64) float64 {
+	return d * math.Pi / 180.0
+}
+
+func radToDeg(r float64) float64 {
+	return r * 180.0 / math.Pi
+}
+
+func modDeg(v float64, m float64) float64 {
+	v = math.Mod(v, m)
+	if v < 0 {
+		v += m
+	}
+	return v
+}
+
+// Declination of the sun (degree) and the equation of time (minutes) at the given time (NOAA solar calculator)
+func sunDeclinationAndEqTime(t time.Time) (float64, float64) {
+	jd := float64(t.Unix())/86400.0 + 2440587.5
+	jc := (jd - 2451545.0) / 36525.0
+
+	meanLong := modDeg(280.46646+jc*(36000.76983+jc*0.0003032), 360.0)
+	meanAnom := 357.52911 + jc*(35999.05029-0.0001537*jc)
+	eccent := 0.016708634 - jc*(0.000042037+0.0000001267*jc)
+	eqCenter := math.Sin(degToRad(meanAnom))*(1.914602-jc*(0.004817+0.000014*jc)) +
+		math.Sin(degToRad(2*meanAnom))*(0.019993-0.000101*jc) +
+		math.Sin(degToRad(3*meanAnom))*0.000289
+	trueLong := meanLong + eqCenter
+	appLong := trueLong - 0.00569 - 0.00478*math.Sin(degToRad(125.04-1934.136*jc))
+	meanObliq := 23.0 + (26.0+(21.448-jc*(46.815+jc*(0.00059-jc*0.001813)))/60.0)/60.0
+	obliqCorr := meanObliq + 0.00256*math.Cos(degToRad(125.04-1934.136*jc))
+	declination := radToDeg(math.Asin(math.Sin(degToRad(obliqCorr)) * math.Sin(degToRad(appLong))))
+
+	y := math.Pow(math.Tan(degToRad(obliqCorr/2.0)), 2)
+	eqTime := 4.0 * radToDeg(y*math.Sin(2*degToRad(meanLong))-
+		2*eccent*math.Sin(degToRad(meanAnom))+
+		4*eccent*y*math.Sin(degToRad(meanAnom))*math.Cos(2*degToRad(meanLong))-
+		0.5*y*y*math.Sin(4*degToRad(meanLong))-
+		1.25*eccent*eccent*math.Sin(2*degToRad(meanAnom)))
+	return declination, eqTime
+}
+
+// Returns the time of the sun event on the day of the given time (in the location of the day).
+// The second return value is false if the event does not happen on that day (polar day or night).
+func SunEventTime(event string, day time.Time) (time.Time, bool) {
+	if !LocationSet {
+		return time.Time{}, false
+	}
+	midnightUtc := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	// The values are calculated at the approximate solar noon of the location
+	declination, eqTime := sunDeclinationAndEqTime(midnightUtc.Add(time.Duration((720.0 - 4.0*LocationLongitude) * float64(time.Minute))))
+	solarNoon := 720.0 - 4.0*LocationLongitude - eqTime
+
+	zenith := sunZenithSunrise
+	if event == "dawn" || event == "dusk" {
+		zenith = sunZenithCivil
+	}
+	if event == "noon" {
+		return midnightUtc.Add(time.Duration(solarNoon * float64(time.Minute))).In(day.Location()), true
+	}
+
+	lat := degToRad(LocationLatitude)
+	decl := degToRad(declination)
+	cosHa := math.Cos(degToRad(zenith))/(math.Cos(lat)*math.Cos(decl)) - math.Tan(lat)*math.Tan(decl)
+	if cosHa < -1.0 || cosHa > 1.0 {
+		return time.Time{}, false
+	}
+	ha := radToDeg(math.Acos(cosHa))
+
+	minutes := solarNoon
+	if event == "sunrise" || event == "dawn" {
+		minutes -= 4.0 * ha
+	} else if event == "sunset" || event == "dusk" {
+		minutes += 4.0 * ha
+	} else {
+		return time.Time{}, false
+	}
+	return midnightUtc.Add(time.Duration(minutes * float64(time.Minute))).In(day.Location()), true
+}
+
+// Elevation and azimuth (degree, north = 0) of the sun at the given time
+func SunPosition(t time.Time) (float64, float64) {
+	utc := t.UTC()
+	declination, eqTime := sunDeclinationAndEqTime(utc)
+	minutes := float64(utc.Hour()*60+utc.Minute()) + float64(utc.Second())/60.0
+	trueSolarTime := modDeg(minutes+eqTime+4.0*LocationLongitude, 1440.0)
+	hourAngle := trueSolarTime/4.0 - 180.0
+
+	lat := degToRad(LocationLatitude)
+	decl := degToRad(declination)
+	cosZenith := math.Sin(lat)*math.Sin(decl) + math.Cos(lat)*math.Cos(decl)*math.Cos(degToRad(hourAngle))
+	zenith := radToDeg(math.Acos(math.Max(-1.0, math.Min(1.0, cosZenith))))
+
+	azimuth := 0.0
+	denominator := math.Cos(lat) * math.Sin(degToRad(zenith))
+	if denominator != 0 {
+		cosAz := (math.Sin(lat)*math.Cos(degToRad(zenith)) - math.Sin(decl)) / denominator
+		az := radToDeg(math.Acos(math.Max(-1.0, math.Min(1.0, cosAz))))
+		if hourAngle > 0 {
+			azimuth = modDeg(az+180.0, 360.0)
+		} else {
+			azimuth = modDeg(540.0-az, 360.0)
+		}
+	}
+	return 90.0 - zenith, azimuth
+}
+
+func sunEventHM(event string, day time.Time) string {
+	t, ok := SunEventTime(event, day)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%02d:%02d", t.Hour(), t.Minute())
+}
+
+// Adds the Sun.* variables to the program variables, only if the location is set
+func AddSunVariables(ctx *RunContext, now time.Time) {
+	if !LocationSet {
+		return
+	}
+	elevation, azimuth := SunPosition(now)
+	ctx.variables["Sun.Sunrise"] = sunEventHM("sunrise", now)
+	ctx.variables["Sun.Sunset"] = sunEventHM("sunset", now)
+	ctx.variables["Sun.Dawn"] = sunEventHM("dawn", now)
+	ctx.variables["Sun.Dusk"] = sunEventHM("dusk", now)
+	ctx.variables["Sun.Noon"] = sunEventHM("noon", now)
+	ctx.variables["Sun.Elevation"] = fmt.Sprintf("%.1f", elevation)
+	ctx.variables["Sun.Azimuth"] = fmt.Sprintf("%.1f", azimuth)
+	ctx.variables["Sun.IsDay"] = fmt.Sprintf("%t", elevation > 90.0-sunZenithSunrise)
+}
+
+func isSunEvent(event string) bool {
+	for _, e := range SunEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Parses a sun based time like "sunset", "sunset+15" or "sunrise-30" (offset in minutes)
+func ParseSunTimeSpec(spec string) (string, int, bool) {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	i := strings.IndexAny(spec, "+-")
+	if i < 0 {
+		return spec, 0, isSunEvent(spec)
+	}
+	offset, err := strconv.Atoi(spec[i:])
+	if err != nil || !isSunEvent(spec[:i]) {
+		return "", 0, false
+	}
+	return spec[:i], offset, true
+}
+
+func SunTimeSpec(event string, offset int) string {
+	if offset == 0 {
+		return event
+	}
+	return fmt.Sprintf("%s%+d", event, offset)
+}
@@ -149,6 +149,11 @@ func (p PanelSwitch) IsActionIdMatch(aId string) bool {
 }
 
 func (p *PanelSwitch) DoAction(actionName string, parameters map[string]string) (string, []string, bool) {
+	result, updatedIds, stateChanged, _ := p.DoDeviceAction(actionName, parameters)
+	return result, updatedIds, stateChanged
+}
+
+func (p *PanelSwitch) DoDeviceAction(actionName string, parameters map[string]string) (string, []string, bool, error) {
 	var stateChanged bool = false
 	var updatedIds []string = []string{}
 
@@ -164,28 +169,41 @@ func (p *PanelSwitch) DoAction(actionName string, parameters map[string]string)
 		}
 		time.Sleep(time.Millisecond * 200)
 		updatedIds = append(updatedIds, p.QueryDevice()...)
-		return "ok", updatedIds, stateChanged
+		if !r.ok {
+			return "ok", updatedIds, stateChanged, deviceOperationError(r.err)
+		}
+		return "ok", updatedIds, stateChanged, nil
 	}
 
 	if actionName == "update" {
 		updatedIds = append(updatedIds, p.QueryDevice()...)
-		return "ok", updatedIds, stateChanged
+		return "ok", updatedIds, stateChanged, nil
 	}
 
-	return "ok", updatedIds, stateChanged
+	return "ok", updatedIds, stateChanged, nil
 }
 
-func (p *PanelSwitch) DoActionFromScheduler(actionName string) []string {
+func (p *PanelSwitch) DoActionFromScheduler(actionName string) ([]string, error) {
 	if actionName == "on" || actionName == "off" {
 		toState := false
 		if actionName == "on" {
 			toState = true
 		}
-		p.deviceHandler.SwitchTo(p, toState, "swscheduler")
+		r := p.deviceHandler.SwitchTo(p, toState, "swscheduler")
 		time.Sleep(time.Millisecond * 200)
-		return p.QueryDevice()
+		if !r.ok {
+			return p.QueryDevice(), deviceOperationError(r.err)
+		}
+		return p.QueryDevice(), nil
+	}
+	return []string{}, errUnknownScheduledAction
+}
+
+func (p PanelSwitch) SchedulableActions() []SchedulableAction {
+	return []SchedulableAction{
+		{Name: "on", Title: "Switch On"},
+		{Name: "off", Title: "Switch Off"},
 	}
-	return []string{}
 }
 
 func (p *PanelSwitch) QueryDevice() []string {
@@ -234,6 +252,7 @@ func (p *PanelSwitch) RefreshHwStateIfMatchSwitch(fromPanelType PanelTypes, from
 		if p.deviceIp == "" && pId != p.idStr {
 			return "" // (Probably) independent device without hw info.
 		}
+		AutomationPanelStateUpdate(p.idStr, p.hasValidInfo, p.state, State, p.inputState, InputState)
 		p.state = State
 		p.inputState = InputState
 		p.hasValidInfo = true
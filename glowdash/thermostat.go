@@ -306,17 +306,42 @@ func (p PanelThermostat) DoAction(actionName string, parameters map[string]strin
 	return "ok", updatedIds, stateChanged
 }
 
-func (p PanelThermostat) DoActionFromScheduler(actionName string) []string {
+// The target temperature is tts/value, the older schedules store only the value
+func (p PanelThermostat) DoActionFromScheduler(actionName string) ([]string, error) {
 	if p.deviceType == "smtherm" && p.hwDeviceIp != "" {
-		f, converr := strconv.ParseFloat(actionName, 8)
-		if converr == nil {
+		if work, found := strings.CutPrefix(actionName, "work/"); found && (work == "on" || work == "off") {
+			GlowdashConsole.Write(fmt.Sprintf("Scheduled set thermostat \"%s\" to &lt;%s&gt;", p.eventtitle, work))
+			response := execTcpQuery(p.hwDeviceIp, p.hwDevicePort, fmt.Sprintf("cmd:stw;work:%s;", work))
+			time.Sleep(time.Millisecond * 500)
+			if len(response) == 0 {
+				return p.QueryDevice(), deviceOperationError(fmt.Errorf("no response from %s:%d", p.hwDeviceIp, p.hwDevicePort))
+			}
+			return p.QueryDevice(), nil
+		}
+		f, converr := strconv.ParseFloat(strings.TrimPrefix(actionName, "tts/"), 8)
+		if converr == nil && p.panelType == Thermostat {
 			GlowdashConsole.Write(fmt.Sprintf("Scheduled set thermostat \"%s\" target temperature to &lt;%.1f&gt;", p.eventtitle, f))
-			execTcpQuery(p.hwDeviceIp, p.hwDevicePort, fmt.Sprintf("cmd:stt;ttemp:%.1f;", f))
+			response := execTcpQuery(p.hwDeviceIp, p.hwDevicePort, fmt.Sprintf("cmd:stt;ttemp:%.1f;", f))
 			time.Sleep(time.Millisecond * 500)
-			return p.QueryDevice()
+			if len(response) == 0 {
+				return p.QueryDevice(), deviceOperationError(fmt.Errorf("no response from %s:%d", p.hwDeviceIp, p.hwDevicePort))
+			}
+			return p.QueryDevice(), nil
 		}
 	}
-	return []string{}
+	return []string{}, errUnknownScheduledAction
+}
+
+func (p PanelThermostat) SchedulableActions() []SchedulableAction {
+	actions := []SchedulableAction{}
+	if p.deviceType != "smtherm" {
+		return actions
+	}
+	if p.panelType == Thermostat {
+		actions = append(actions, SchedulableAction{Name: "tts", Title: "Target temperature", Param: ParamNumber, Min: 5, Max: 30, Step: 0.5})
+	}
+	actions = append(actions, SchedulableAction{Name: "work", Title: "Working", Param: ParamBool})
+	return actions
 }
 
 func (p PanelThermostat) QueryDevice() []string {
@@ -369,6 +394,8 @@ func (p *PanelThermostat) RefreshHwStateIfMatchThermostat(fromPanelType PanelTyp
 	if (fromPanelType == Thermostat || fromPanelType == ThermostatSwitch) &&
 		(p.panelType == Thermostat || p.panelType == ThermostatSwitch) &&
 		p.hwDeviceIp == fromDeviceIp && p.hwDevicePort == fromDevicePort {
+		AutomationSensorUpdate(p.idStr, "reference", "Temp", p.hasValidInfo, float64(p.referenceTemp), float64(rt))
+		AutomationPanelStateUpdate(p.idStr, p.hasValidInfo, IntFromBool(p.heatingOn), IntFromBool(ho), 0, 0)
 		p.tartgetTemp = tt
 		p.referenceTemp = rt
 		p.heatingOn = ho
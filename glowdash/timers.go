@@ -0,0 +1,114 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Named timer which runs a library program when expires
+type ScriptTimer struct {
+	name    string
+	program string
+	due     time.Time
+	timer   *time.Timer
+	seq     int
+}
+
+var scriptTimers map[string]*ScriptTimer = map[string]*ScriptTimer{}
+var scriptTimersMutex sync.Mutex
+var scriptTimersSeq int = 0
+
+// Starts the named timer. If the timer is already running it is only restarted when restart is true.
+// Returns false if the timer was running and not restarted.
+func startScriptTimer(name string, delay time.Duration, program string, restart bool) bool {
+	scriptTimersMutex.Lock()
+	defer scriptTimersMutex.Unlock()
+
+	if st, ok := scriptTimers[name]; ok {
+		if !restart {
+			return false
+		}
+		st.timer.Stop()
+	}
+
+	scriptTimersSeq++
+	seq := scriptTimersSeq
+	st := &ScriptTimer{
+		name:    name,
+		program: program,
+		due:     time.Now().Add(delay),
+		seq:     seq,
+	}
+	st.timer = time.AfterFunc(delay, func() { fireScriptTimer(name, seq) })
+	scriptTimers[name] = st
+
+	if DebugLevel > 2 {
+		fmt.Printf("Timer \"%s\" started, runs \"%s\" after %s\n", name, program, delay)
+	}
+	return true
+}
+
+func cancelScriptTimer(name string) bool {
+	scriptTimersMutex.Lock()
+	defer scriptTimersMutex.Unlock()
+
+	st, ok := scriptTimers[name]
+	if !ok {
+		return false
+	}
+	st.timer.Stop()
+	delete(scriptTimers, name)
+	if DebugLevel > 2 {
+		fmt.Printf("Timer \"%s\" cancelled\n", name)
+	}
+	return true
+}
+
+// Returns the remaining time of the named timer, the second value is false if the timer is not running
+func scriptTimerRemaining(name string) (time.Duration, bool) {
+	scriptTimersMutex.Lock()
+	defer scriptTimersMutex.Unlock()
+
+	st, ok := scriptTimers[name]
+	if !ok {
+		return 0, false
+	}
+	remaining := time.Until(st.due)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, true
+}
+
+func fireScriptTimer(name string, seq int) {
+	scriptTimersMutex.Lock()
+	st, ok := scriptTimers[name]
+	if !ok || st.seq != seq {
+		// Cancelled or restarted meanwhile
+		scriptTimersMutex.Unlock()
+		return
+	}
+	delete(scriptTimers, name)
+	scriptTimersMutex.Unlock()
+
+	code, found := getLibraryProgram(st.program)
+	if !found {
+		GlowdashConsole.Write(T("ERROR: Timer \"{{timer}}\" expired but the program \"{{program}}\" is not found",
+			map[string]any{"timer": name, "program": st.program}))
+		return
+	}
+
+	GlowdashConsole.Write(T("Timer \"{{timer}}\" expired, run \"{{program}}\"", map[string]any{"timer": name, "program": st.program}))
+	variables := map[string]string{}
+	variables["Timer.Name"] = name
+	variables["Timer.Program"] = st.program
+	startBackgroundProgram(code, variables, LibraryProgramRunOptions(st.program), nil)
+}
@@ -0,0 +1,80 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"time"
+	_ "time/tzdata"
+)
+
+// The timezone of all time logic (scheduler, script time variables, console).
+// It is the local timezone of the system if the TimeZone is not set in the config.
+var TimeLocation *time.Location = time.Local
+
+// Sets the timezone by IANA name (e.g. "Europe/Budapest"). The empty name or "Local" is the system timezone.
+// The timezone database is embedded, so it works in containers without zoneinfo too.
+func SetTimeZone(name string) error {
+	if name == "" {
+		TimeLocation = time.Local
+		return nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return err
+	}
+	TimeLocation = loc
+	return nil
+}
+
+// The current time in the configured timezone
+func Now() time.Time {
+	return time.Now().In(TimeLocation)
+}
+
+func zoneOffset(t time.Time) int {
+	_, offset := t.Zone()
+	return offset
+}
+
+// Returns the wall clock minutes which were skipped by the DST change (spring forward) right before the
+// given minute. The returned times have the wall clock of the skipped minutes (in the offset before the change),
+// so they can be matched with the schedules. Returns nil if there was no forward change before this minute.
+func dstSkippedMinutes(t time.Time) []time.Time {
+	current := t.Truncate(time.Minute)
+	previous := current.Add(-time.Minute)
+	gap := zoneOffset(current) - zoneOffset(previous)
+	if gap <= 0 {
+		return nil
+	}
+	zone := time.FixedZone("", zoneOffset(previous))
+	skipped := []time.Time{}
+	for m := 1; m <= gap/60; m++ {
+		skipped = append(skipped, previous.In(zone).Add(time.Duration(m)*time.Minute))
+	}
+	return skipped
+}
+
+// Checks if the wall clock minute of the given time is the second occurrence of a repeated
+// minute (the clock is turned back at the end of DST). The repeated minutes are not run again.
+func dstRepeatedMinute(t time.Time) bool {
+	offset := zoneOffset(t)
+	before := zoneOffset(t.Add(-3 * time.Hour))
+	if before <= offset {
+		return false
+	}
+	return zoneOffset(t.Add(-time.Duration(before-offset)*time.Second)) == before
+}
+
+// Returns the time of the next scheduler run: the start of the next minute plus the configured second
+func nextSchedulerRun(now time.Time) time.Time {
+	next := now.Truncate(time.Minute).Add(time.Duration(SchedulerFireSecond) * time.Second)
+	if !next.After(now) {
+		next = next.Add(time.Minute)
+	}
+	return next
+}
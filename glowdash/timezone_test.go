@@ -0,0 +1,144 @@
+/*
+	GlowDash - Smart Home Web Dashboard
+
+	(C) 2024-2026 Péter Deák (hyper80@gmail.com)
+	License: GPLv2
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("cannot load %s: %s", name, err)
+	}
+	return loc
+}
+
+func TestDstSkippedMinutes(t *testing.T) {
+	budapest := mustLoadLocation(t, "Europe/Budapest")
+	lordHowe := mustLoadLocation(t, "Australia/Lord_Howe")
+	tests := []struct {
+		name  string
+		t     time.Time
+		count int
+		first string
+		last  string
+	}{
+		{"normal minute", time.Date(2026, 3, 28, 3, 0, 0, 0, budapest), 0, "", ""},
+		{"first minute after the jump", time.Date(2026, 3, 29, 1, 0, 0, 0, time.UTC).In(budapest), 60, "02:00", "02:59"},
+		{"first minute after the jump, later second", time.Date(2026, 3, 29, 1, 0, 40, 0, time.UTC).In(budapest), 60, "02:00", "02:59"},
+		{"second minute after the jump", time.Date(2026, 3, 29, 1, 1, 0, 0, time.UTC).In(budapest), 0, "", ""},
+		{"minute before the jump", time.Date(2026, 3, 29, 0, 59, 0, 0, time.UTC).In(budapest), 0, "", ""},
+		{"clock turned back", time.Date(2026, 10, 25, 1, 0, 0, 0, time.UTC).In(budapest), 0, "", ""},
+		{"half hour jump", time.Date(2026, 10, 4, 2, 30, 0, 0, lordHowe), 30, "02:00", "02:29"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			skipped := dstSkippedMinutes(tc.t)
+			if len(skipped) != tc.count {
+				t.Fatalf("got %d skipped minutes, want %d", len(skipped), tc.count)
+			}
+			if tc.count == 0 {
+				return
+			}
+			if got := skipped[0].Format("15:04"); got != tc.first {
+				t.Errorf("first skipped minute is %s, want %s", got, tc.first)
+			}
+			if got := skipped[len(skipped)-1].Format("15:04"); got != tc.last {
+				t.Errorf("last skipped minute is %s, want %s", got, tc.last)
+			}
+		})
+	}
+}
+
+func TestDstRepeatedMinute(t *testing.T) {
+	budapest := mustLoadLocation(t, "Europe/Budapest")
+	newYork := mustLoadLocation(t, "America/New_York")
+	tests := []struct {
+		name string
+		t    time.Time
+		want bool
+	}{
+		{"normal minute", time.Date(2026, 10, 24, 2, 30, 0, 0, budapest), false},
+		{"first occurrence of 02:00", time.Date(2026, 10, 25, 0, 0, 0, 0, time.UTC).In(budapest), false},
+		{"first occurrence of 02:59", time.Date(2026, 10, 25, 0, 59, 0, 0, time.UTC).In(budapest), false},
+		{"second occurrence of 02:00", time.Date(2026, 10, 25, 1, 0, 0, 0, time.UTC).In(budapest), true},
+		{"second occurrence of 02:30", time.Date(2026, 10, 25, 1, 30, 0, 0, time.UTC).In(budapest), true},
+		{"second occurrence of 02:59", time.Date(2026, 10, 25, 1, 59, 0, 0, time.UTC).In(budapest), true},
+		{"03:00 after the change", time.Date(2026, 10, 25, 2, 0, 0, 0, time.UTC).In(budapest), false},
+		{"spring jump", time.Date(2026, 3, 29, 1, 0, 0, 0, time.UTC).In(budapest), false},
+		{"second occurrence of 01:15 in New York", time.Date(2026, 11, 1, 6, 15, 0, 0, time.UTC).In(newYork), true},
+		{"first occurrence of 01:15 in New York", time.Date(2026, 11, 1, 5, 15, 0, 0, time.UTC).In(newYork), false},
+		{"UTC", time.Date(2026, 10, 25, 1, 30, 0, 0, time.UTC), false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := dstRepeatedMinute(tc.t); got != tc.want {
+				t.Errorf("dstRepeatedMinute(%s) = %v, want %v", tc.t, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNextSchedulerRun(t *testing.T) {
+	saved := SchedulerFireSecond
+	defer func() { SchedulerFireSecond = saved }()
+	tests := []struct {
+		name   string
+		second int
+		now    time.Time
+		want   time.Time
+	}{
+		{"start of minute", 0, time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC), time.Date(2026, 5, 1, 10, 1, 0, 0, time.UTC)},
+		{"middle of minute", 0, time.Date(2026, 5, 1, 10, 0, 30, 0, time.UTC), time.Date(2026, 5, 1, 10, 1, 0, 0, time.UTC)},
+		{"end of day", 0, time.Date(2026, 5, 1, 23, 59, 59, 900, time.UTC), time.Date(2026, 5, 2, 0, 0, 0, 0, time.UTC)},
+		{"before fire second", 15, time.Date(2026, 5, 1, 10, 0, 10, 0, time.UTC), time.Date(2026, 5, 1, 10, 0, 15, 0, time.UTC)},
+		{"at fire second", 15, time.Date(2026, 5, 1, 10, 0, 15, 0, time.UTC), time.Date(2026, 5, 1, 10, 1, 15, 0, time.UTC)},
+		{"after fire second", 15, time.Date(2026, 5, 1, 10, 0, 20, 0, time.UTC), time.Date(2026, 5, 1, 10, 1, 15, 0, time.UTC)},
+		{"last fire second", 59, time.Date(2026, 5, 1, 10, 0, 58, 999, time.UTC), time.Date(2026, 5, 1, 10, 0, 59, 0, time.UTC)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			SchedulerFireSecond = tc.second
+			if got := nextSchedulerRun(tc.now); !got.Equal(tc.want) {
+				t.Errorf("nextSchedulerRun(%s) = %s, want %s", tc.now, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestScheduleRunsInRepeatedHour(t *testing.T) {
+	tests := []struct {
+		name     string
+		kind     string
+		interval int
+		cron     string
+		oneshot  bool
+		want     bool
+	}{
+		{"fixed time", "", 0, "", false, false},
+		{"one shot", "", 0, "", true, true},
+		{"interval of 15 minutes", "interval", 15, "", false, true},
+		{"interval of 90 minutes", "interval", 90, "", false, false},
+		{"cron every 5 minutes", "cron", 0, "*/5 * * * *", false, true},
+		{"cron every hour", "cron", 0, "30 * * * *", false, true},
+		{"cron at fixed hour", "cron", 0, "30 2 * * *", false, false},
+		{"date", "date", 0, "", false, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := nullSchedule()
+			s.kind, s.interval, s.cron, s.oneshot = tc.kind, tc.interval, tc.cron, tc.oneshot
+			if got := scheduleRunsInRepeatedHour(s); got != tc.want {
+				t.Errorf("scheduleRunsInRepeatedHour() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -9,14 +9,18 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/hyper-prog/smartjson"
@@ -41,6 +45,25 @@ type JsonHttpQuery struct {
 	SmartJSON    smartjson.SmartJSON
 }
 
+type HttpRequestSpec struct {
+	Method      string
+	Url         string
+	Headers     map[string]string
+	Body        string
+	Timeout     time.Duration
+	AuthUser    string
+	AuthPass    string
+	BearerToken string
+}
+
+type HttpQueryResult struct {
+	Success      bool
+	ErrorMessage string
+	StatusCode   int
+	Body         []byte
+	Headers      http.Header
+}
+
 type JsonTcpQuery struct {
 	Success      bool
 	ErrorMessage string
@@ -55,6 +78,13 @@ func TrueFalseTextFromBool(b bool) string {
 	return "false"
 }
 
+func IntFromBool(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 func IfTrue(b bool, text string) string {
 	if b {
 		return text
@@ -144,6 +174,14 @@ func GetWindInfo_WeatherApiCom() WindInfo {
 	return WindInfo{time.Now(), wind, gust}
 }
 
+// The error of the failed query (nil if it was successful)
+func (jhq JsonHttpQuery) Err() error {
+	if jhq.Success {
+		return nil
+	}
+	return errors.New(strings.TrimSpace(jhq.ErrorMessage))
+}
+
 func execJsonHttpQuery(url string) JsonHttpQuery {
 	start := time.Now()
 	jhq := JsonHttpQuery{}
@@ -192,6 +230,7 @@ func execJsonHttpQuery(url string) JsonHttpQuery {
 			fmt.Printf("Error parsing json result.\n")
 		}
 		jhq.Success = false
+		jhq.ErrorMessage = "Error parsing json result"
 		return jhq
 	}
 	jhq.SmartJSON = sj
@@ -200,6 +239,106 @@ func execJsonHttpQuery(url string) JsonHttpQuery {
 	return jhq
 }
 
+// The shared client of the HttpRequest calls, so the connections are reused. It is created on the first call,
+// after the dialer timeouts are read from the config. The timeout of the request is set by its context.
+var httpRequestClient *http.Client
+var httpRequestClientOnce sync.Once
+
+func getHttpRequestClient() *http.Client {
+	httpRequestClientOnce.Do(func() {
+		httpRequestClient = &http.Client{
+			Transport: &http.Transport{
+				DialContext: (&net.Dialer{
+					Timeout:   BackgroudDevQueryNetDialerTimeout,
+					KeepAlive: BackgroudDevQueryNetKeepaliveTimeout,
+				}).DialContext,
+				IdleConnTimeout: 90 * time.Second,
+			},
+		}
+	})
+	return httpRequestClient
+}
+
+func execHttpRequest(spec HttpRequestSpec) HttpQueryResult {
+	start := time.Now()
+	hqr := HttpQueryResult{Success: false, StatusCode: 0, Body: []byte{}, Headers: http.Header{}}
+
+	method := strings.ToUpper(strings.TrimSpace(spec.Method))
+	if method == "" {
+		method = "GET"
+	}
+
+	if DebugLevel > 0 {
+		fmt.Printf("CALL %s -> %s\n", method, spec.Url)
+	}
+
+	timeout := spec.Timeout
+	if timeout <= 0 {
+		timeout = HttpRequestDefaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var bodyReader io.Reader = nil
+	if spec.Body != "" {
+		bodyReader = strings.NewReader(spec.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, spec.Url, bodyReader)
+	if err != nil {
+		hqr.ErrorMessage = fmt.Sprintf("Error creating http request: %s", err)
+		if DebugLevel > 1 {
+			fmt.Println(hqr.ErrorMessage)
+		}
+		return hqr
+	}
+
+	for name, value := range spec.Headers {
+		req.Header.Set(name, value)
+	}
+	if spec.AuthUser != "" {
+		req.SetBasicAuth(spec.AuthUser, spec.AuthPass)
+	}
+	if spec.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+spec.BearerToken)
+	}
+
+	res, err := getHttpRequestClient().Do(req)
+	if err != nil {
+		hqr.ErrorMessage = fmt.Sprintf("Error making http request: %s", err)
+		if DebugLevel > 1 {
+			fmt.Println(hqr.ErrorMessage)
+		}
+		return hqr
+	}
+	defer res.Body.Close()
+
+	hqr.StatusCode = res.StatusCode
+	hqr.Headers = res.Header
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		hqr.ErrorMessage = fmt.Sprintf("Error reading http result: %s", err)
+		if DebugLevel > 1 {
+			fmt.Println(hqr.ErrorMessage)
+		}
+		return hqr
+	}
+	hqr.Body = body
+
+	if DebugLevel > 1 {
+		fmt.Printf("RESPONSE (%d) -> %s\nElapsed time: %s\n", res.StatusCode, string(body), time.Since(start))
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		hqr.ErrorMessage = fmt.Sprintf("Http request returned status %d", res.StatusCode)
+		return hqr
+	}
+	hqr.Success = true
+	return hqr
+}
+
 func execTcpQuery(ip string, port int, sendData string) []byte {
 	start := time.Now()
 
@@ -338,7 +477,36 @@ func (ar *ActionResponse) getResponseString() string {
 	return fmt.Sprintf("{\"result\":\"%s\",\"cmds\":[%s]}\n", ar.resultStr, cmdpart)
 }
 
+// Writes the file through a temporary file and rename, so the file is never left half written
+func writeFileAtomic(filename string, content []byte) error {
+	// Unique temporary file in the same directory, so the concurrent writes do not clash and the rename is atomic
+	f, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpname := f.Name()
+	err = f.Chmod(0644)
+	if err == nil {
+		_, err = f.Write(content)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmpname)
+		return err
+	}
+	return os.Rename(tmpname, filename)
+}
+
+// Sends the notify message to the built-in SSE server and/or to the external Hasses server
 func sendSSENotify(message string) {
+	if BuiltinSSEEnabled() {
+		ssePublish(message)
+	}
 	if CommUseSSE == 0 {
 		return
 	}
@@ -27,6 +27,7 @@ function runCommand(cmd) {
         initUnravedGauges();
         initClockPickerBlocks();
         initActionSubselector();
+        initScheduleKindSelector();
         return;
     }
     if(parts[0] == "loadpage" && parts.length == 2) {
@@ -243,11 +244,12 @@ function handleDeferredInfos() {
 }
 
 function startSSE() {
-    if(conf_use_sse === undefined || conf_use_sse != 1)
+    if(conf_use_sse === undefined || (conf_use_sse != 1 && conf_use_sse != 2))
         return;
 
-    let sseurl = window.location.protocol + "//" + window.location.hostname + ":" +
-                 conf_sse_port + "/sse?id="+ot_sse_id+"&subscribe=thermostat-sensors-panelupd";
+    let sseurl = "/sse?id="+ot_sse_id+"&subscribe=thermostat-sensors-panelupd";
+    if(conf_use_sse == 1)
+        sseurl = window.location.protocol + "//" + window.location.hostname + ":" + conf_sse_port + sseurl;
     const eventSource = new EventSource(sseurl);
     eventSource.onmessage = function(event) {
         if(event.data == "Hello")
@@ -467,36 +469,13 @@ function initClockPickerBlocks() {
     }
 }
 
-function t(key) {
-  return window.TRANSLATIONS[key] || key;
-}
-
-function fillActionSubselect(main_select_value,subselect_id) {
-    if(main_select_value.substr(0,7) == "switch:") {
-        document.getElementById(subselect_id).innerHTML =
-            '<option value="on">' + t("Switch On") + '</option>'+
-            '<option value="off">' + t("Switch Off") + '</option>';
-    }
-    if(main_select_value.substr(0,7) == "action:") {
-        document.getElementById(subselect_id).innerHTML =
-            '<option value="run">' + t("Run") + '</option>';
-    }
-    if(main_select_value.substr(0,8) == "shading:") {
-        document.getElementById(subselect_id).innerHTML =
-            '<option value="open">' + t("Open") + '</option>'+
-            '<option value="close">' + t("Close") + '</option>';
-    }
-    if(main_select_value.substr(0,7) == "script:") {
-        document.getElementById(subselect_id).innerHTML =
-            '<option value="start">' + t("Start") + '</option>'+
-            '<option value="stop">' + t("Stop") + '</option>';
-    }
-    if(main_select_value.substr(0,6) == "therm:") {
-        let str = "";
-        for(t=5.0;t<=30;t+=0.5) {
-            str += "<option value=\"" + t.toString() + "\">" + t.toString() + "</option>";
-        }
-        document.getElementById(subselect_id).innerHTML = str;
+function fillActionSubselect(main_select_value,subselect_id,actions) {
+    const panelId = main_select_value.substr(main_select_value.indexOf(":") + 1);
+    const options = actions[panelId] || [];
+    const subselect = document.getElementById(subselect_id);
+    subselect.innerHTML = "";
+    for(let i = 0; i < options.length; i++) {
+        subselect.add(new Option(options[i].t, options[i].v));
     }
 }
 
@@ -506,13 +485,41 @@ function initActionSubselector() {
         if(allActionSelector[i].classList.contains('action-selector-processed'))
             continue;
         let actionSubId = allActionSelector[i].dataset.actionsubid;
+        let actionEndId = allActionSelector[i].dataset.actionendid;
+        let actions = JSON.parse(allActionSelector[i].dataset.actions || "{}");
         allActionSelector[i].addEventListener('change',function(e){
-            fillActionSubselect(e.target.value,actionSubId);
+            fillActionSubselect(e.target.value,actionSubId,actions);
+            if(actionEndId) {
+                fillActionSubselect(e.target.value,actionEndId,actions);
+                let endSelect = document.getElementById(actionEndId);
+                if(endSelect.options.length == 2)
+                    endSelect.selectedIndex = 1;
+            }
         });
         allActionSelector[i].classList.add('action-selector-processed');
     }
 }
 
+function showScheduleKindBlocks(selector) {
+    const form = selector.closest("form");
+    form.querySelectorAll("[data-schedkinds]").forEach(block => {
+        block.style.display = block.dataset.schedkinds.split(" ").includes(selector.value) ? "" : "none";
+    });
+}
+
+function initScheduleKindSelector() {
+    const allKindSelector = document.getElementsByClassName("schedule-kind-selector");
+    for (let i = 0; i < allKindSelector.length; i++) {
+        if(allKindSelector[i].classList.contains('kind-selector-processed'))
+            continue;
+        allKindSelector[i].addEventListener('change',function(e){
+            showScheduleKindBlocks(e.target);
+        });
+        showScheduleKindBlocks(allKindSelector[i]);
+        allKindSelector[i].classList.add('kind-selector-processed');
+    }
+}
+
 function updateTime() {
     const today = new Date();
     let h = today.getHours();